@@ -13,7 +13,19 @@ webhook = os.environ.get('WEBHOOK')
 
 hookfile = sys.argv[1] if len(sys.argv) > 1 else None
 
+# ECR tokens expire after 12 hours, so pull secrets are refreshed well before
+pull_secret_namespaces = os.environ.get('PULL_SECRET_NAMESPACES', '').split()
+pull_secret_service_accounts = os.environ.get('PULL_SECRET_SERVICE_ACCOUNTS', '').split()
+pull_secret_interval = 6 * 60 * 60
+pull_secret_synced = 0
+
 while True:
+    if pull_secret_namespaces and time.time() - pull_secret_synced > pull_secret_interval:
+        command = ["./k8ecr", "pull-secret", "sync"]
+        for sa in pull_secret_service_accounts:
+            command += ["--service-account", sa]
+        if subprocess.run(command + pull_secret_namespaces).returncode == 0:
+            pull_secret_synced = time.time()
     if hookfile:
         p = subprocess.run(["./k8ecr", "-w", hookfile, "deploy", namespace, "-"], stdout=subprocess.PIPE)
     else:
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/apps"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+	"github.com/isotoma/k8ecr/pkg/registry"
+)
+
+// AdoptCommand mirrors the external images of a namespace into ECR and
+// points the workloads at the mirrors
+type AdoptCommand struct {
+	ClusterOptions  `group:"Cluster Options"`
+	RepositoryFlags `group:"Repository Options"`
+	Pin             string `long:"pin" choice:"tag" choice:"tag-digest" choice:"digest" default:"tag" description:"Write image references by tag, tag and digest, or digest alone"`
+	Yes             bool   `short:"y" long:"yes" description:"Adopt without asking for confirmation"`
+	SourceUsername  string `long:"source-username" env:"K8ECR_SOURCE_USERNAME" description:"Username for the source registries"`
+	SourcePassword  string `long:"source-password" env:"K8ECR_SOURCE_PASSWORD" description:"Password for the source registries"`
+}
+
+var adoptCommand AdoptCommand
+
+func adoptedRepository(id apps.ImageIdentifier) string {
+	_, repo := registry.Normalize(id.Registry, id.Repo)
+	return mirrorRepository(repo)
+}
+
+// Execute the adopt command
+func (x *AdoptCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) < 1 {
+		return errors.New("Usage: k8ecr adopt NAMESPACE [APP...]")
+	}
+	options, err := x.repositoryOptions()
+	if err != nil {
+		return err
+	}
+	cluster, err := x.clusterName()
+	if err != nil {
+		return err
+	}
+	principals, err := x.principals()
+	if err != nil {
+		return err
+	}
+	dst, err := ecr.ClientFor("", "")
+	if err != nil {
+		return err
+	}
+	dst.Progress = printProgress
+	mgr, err := apps.NewAppManagerForContext(x.Context, args[0])
+	if err != nil {
+		return err
+	}
+	adoptions, err := mgr.Adoptions(dst.Host, adoptedRepository, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(adoptions) == 0 {
+		fmt.Println("Every image is already in ECR.")
+		return nil
+	}
+
+	table := uitable.New()
+	table.AddRow("APP", "IMAGE", "VERSION", "ECR REPOSITORY", "CONTAINERS")
+	for _, a := range adoptions {
+		containers := make([]string, 0)
+		for kind, cs := range a.ChangeSet.Containers {
+			for _, c := range cs {
+				containers = append(containers, fmt.Sprintf("%s %s/%s", kind, c.ContainerID.Resource, c.ContainerID.Container))
+			}
+		}
+		table.AddRow(a.App, a.Source.Registry+"/"+a.Source.Repo, a.ChangeSet.UpdateTo, a.ChangeSet.ImageID.Repo, strings.Join(containers, ", "))
+	}
+	fmt.Println(table)
+	if !x.Yes && !confirm(fmt.Sprintf("Mirror %d images into ECR and update their containers?", len(adoptions))) {
+		return nil
+	}
+
+	ecrRegistry := ecr.NewRegistry()
+	created := make(map[string]bool)
+	sources := make(map[string]*registry.Client)
+	for _, a := range adoptions {
+		target := a.ChangeSet.ImageID.Repo
+		if !created[target] {
+			if _, err := ecrRegistry.CreateRepository(target, options, cluster, principals); err != nil {
+				return err
+			}
+			created[target] = true
+		}
+		host, repo := registry.Normalize(a.Source.Registry, a.Source.Repo)
+		src, ok := sources[host]
+		if !ok {
+			src = registry.New(host, x.SourceUsername, x.SourcePassword)
+			sources[host] = src
+		}
+		tags := []string{string(a.ChangeSet.UpdateTo)}
+		if strings.HasPrefix(tags[0], "sha256:") {
+			tags = nil
+		}
+		fmt.Printf("Mirroring %s/%s %s\n", host, repo, a.Reference)
+		digest, err := registry.Copy(src, repo, a.Reference, dst, target, tags)
+		if err != nil {
+			return err
+		}
+		a.ChangeSet.Digests[string(a.ChangeSet.UpdateTo)] = digest
+		mgr.Pin = a.PinMode(pinModes[x.Pin])
+		if err := a.ChangeSet.Upgrade(mgr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	parser.AddCommand("adopt",
+		"Adopt",
+		"Mirror the images a namespace pulls from outside ECR and point its workloads at the mirrors",
+		&adoptCommand)
+}
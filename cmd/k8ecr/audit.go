@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// AuditCommand checks the policies and settings of every repository
+type AuditCommand struct {
+	ClusterOptions `group:"Cluster Options"`
+	Output         string   `short:"o" long:"output" choice:"table" choice:"json" default:"table" description:"Output format"`
+	Fix            bool     `long:"fix" description:"Grant missing access, turn on scan on push and add lifecycle policies"`
+	Lifecycle      []string `long:"lifecycle" default:"expire-untagged" description:"Lifecycle template added by --fix, may be repeated"`
+}
+
+var auditCommand AuditCommand
+
+func (x *AuditCommand) fix(registry *ecr.Registry, f ecr.Finding, cluster string, principals []string, lifecycle string) error {
+	switch f.Check {
+	case ecr.CheckGrant:
+		return registry.Grant(f.Repository, cluster, principals)
+	case ecr.CheckScan:
+		return registry.PutScanOnPush(f.Repository, true)
+	case ecr.CheckLifecycle:
+		return registry.PutLifecyclePolicy(f.Repository, lifecycle)
+	}
+	return nil
+}
+
+// Execute the audit command
+func (x *AuditCommand) Execute(args []string) error {
+	processOptions()
+	cluster, err := x.clusterName()
+	if err != nil {
+		return err
+	}
+	principals, err := x.principals()
+	if err != nil {
+		return err
+	}
+	lifecycle, err := ecr.LifecyclePolicy(x.Lifecycle)
+	if err != nil {
+		return err
+	}
+	registry := ecr.NewRegistry()
+	states, err := registry.RepositoryStates()
+	if err != nil {
+		return err
+	}
+	names := args
+	if len(names) == 0 {
+		for name := range states {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	findings := make([]ecr.Finding, 0)
+	for _, name := range names {
+		state, ok := states[name]
+		if !ok {
+			return fmt.Errorf("Repository %s not found", name)
+		}
+		found, err := ecr.Audit(name, state, cluster, principals)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		findings = append(findings, found...)
+	}
+
+	if x.Output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(findings); err != nil {
+			return err
+		}
+	} else if len(findings) > 0 {
+		table := uitable.New()
+		table.AddRow("REPOSITORY", "CHECK", "PROBLEM")
+		for _, f := range findings {
+			table.AddRow(f.Repository, f.Check, f.Problem)
+		}
+		fmt.Println(table)
+	}
+
+	remaining := 0
+	for _, f := range findings {
+		if x.Fix && f.Fixable {
+			fmt.Fprintf(os.Stderr, "Fixing %s of %s\n", f.Check, f.Repository)
+			if err := x.fix(registry, f, cluster, principals, lifecycle); err != nil {
+				return err
+			}
+			continue
+		}
+		remaining++
+	}
+	if remaining > 0 {
+		return fmt.Errorf("%d problems found in %d repositories", remaining, len(names))
+	}
+	if x.Output != "json" {
+		fmt.Printf("Audited %d repositories.\n", len(names))
+	}
+	return nil
+}
+
+func init() {
+	parser.AddCommand("audit",
+		"Audit",
+		"Check every repository grants this cluster access, is scanned on push and has a lifecycle policy",
+		&auditCommand)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/isotoma/k8ecr/pkg/cluster"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// ClusterOptions identify the cluster that is granted access to repositories
+type ClusterOptions struct {
+	Context    string   `long:"context" description:"Kubeconfig context of the cluster, instead of the current context"`
+	Cluster    string   `long:"cluster" env:"K8ECR_CLUSTER" description:"Name of the cluster, required when running in-cluster"`
+	Principals []string `long:"principal" description:"ARN to grant access to instead of the cluster roles, may be repeated"`
+}
+
+// clusterName returns the cluster name given, or reads it from the kubeconfig
+func (o *ClusterOptions) clusterName() (string, error) {
+	if o.Cluster != "" {
+		return o.Cluster, nil
+	}
+	if cluster.InCluster() {
+		return "", errors.New("Running in-cluster, so --cluster or K8ECR_CLUSTER must be set")
+	}
+	return cluster.Name(o.Context)
+}
+
+// eksClusterName finds the EKS cluster name in the context names used by
+// the aws cli (arn:aws:eks:region:account:cluster/NAME) and eksctl
+// (user@NAME.region.eksctl.io)
+func eksClusterName(name string) string {
+	if i := strings.LastIndex(name, ":cluster/"); i >= 0 {
+		return name[i+len(":cluster/"):]
+	}
+	if strings.HasSuffix(name, ".eksctl.io") {
+		name = name[strings.LastIndex(name, "@")+1:]
+		return strings.Split(name, ".")[0]
+	}
+	return name
+}
+
+// principals returns the principals given, or finds the roles of the
+// cluster's nodes. EKS clusters are recognised by their aws-auth ConfigMap,
+// and otherwise we assume the cluster was created by kops.
+func (o *ClusterOptions) principals() ([]string, error) {
+	if len(o.Principals) > 0 {
+		return o.Principals, nil
+	}
+	name, err := o.clusterName()
+	if err != nil {
+		return nil, err
+	}
+	roles, err := cluster.NodeRoles(o.Context)
+	if err != nil {
+		return nil, err
+	}
+	if roles == nil {
+		return ecr.KopsRoles(name)
+	}
+	Verbose.Println("Found aws-auth ConfigMap, treating", name, "as an EKS cluster")
+	nodegroupRoles, err := ecr.NodegroupRoles(eksClusterName(name))
+	if err != nil {
+		return nil, err
+	}
+	unique := make(map[string]bool)
+	for _, r := range append(roles, nodegroupRoles...) {
+		unique[r] = true
+	}
+	principals := make([]string, 0, len(unique))
+	for r := range unique {
+		principals = append(principals, r)
+	}
+	sort.Strings(principals)
+	if len(principals) == 0 {
+		return nil, errors.New("No node roles found for EKS cluster " + name)
+	}
+	return principals, nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestEksClusterName(T *testing.T) {
+	for name, expected := range map[string]string{
+		"arn:aws:eks:eu-west-1:123456789012:cluster/prod": "prod",
+		"prod.eu-west-1.eksctl.io":                        "prod",
+		"admin@prod.eu-west-1.eksctl.io":                  "prod",
+		"me@example.com@prod.eu-west-1.eksctl.io":         "prod",
+		"minikube": "minikube",
+	} {
+		if actual := eksClusterName(name); actual != expected {
+			T.Errorf("eksClusterName(%q) is %q, expected %q", name, actual, expected)
+		}
+	}
+}
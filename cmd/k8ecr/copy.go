@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/isotoma/k8ecr/pkg/ecr"
+	"github.com/isotoma/k8ecr/pkg/registry"
+)
+
+// CopyCommand copies an image between repositories, accounts or regions
+type CopyCommand struct {
+	SourceProfile string `long:"source-profile" description:"AWS profile for the source registry"`
+	DestProfile   string `long:"dest-profile" description:"AWS profile for the destination registry"`
+}
+
+// RetagCommand adds a tag to an image already in a repository
+type RetagCommand struct{}
+
+var copyCommand CopyCommand
+var retagCommand RetagCommand
+
+func printProgress(digest, status string) {
+	fmt.Printf("%s: %s\n", digest, status)
+}
+
+// Execute the copy command
+func (x *CopyCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) != 2 {
+		return errors.New("Usage: k8ecr copy [HOST/]SRC_REPO:TAG [HOST/]DST_REPO[:TAG]")
+	}
+	srcHost, srcRepo, srcRef := ecr.ParseReference(args[0])
+	dstHost, dstRepo, dstTag := ecr.ParseReference(args[1])
+	if srcRef == "" {
+		return errors.New("The source must have a tag or digest")
+	}
+	if strings.HasPrefix(dstTag, "sha256:") {
+		return errors.New("The destination can only be given a tag")
+	}
+	if dstTag == "" && !strings.HasPrefix(srcRef, "sha256:") {
+		dstTag = srcRef
+	}
+	src, err := ecr.ClientFor(srcHost, x.SourceProfile)
+	if err != nil {
+		return err
+	}
+	dst, err := ecr.ClientFor(dstHost, x.DestProfile)
+	if err != nil {
+		return err
+	}
+	dst.Progress = printProgress
+	tags := []string{}
+	if dstTag != "" {
+		tags = append(tags, dstTag)
+	}
+	digest, err := registry.Copy(src, srcRepo, srcRef, dst, dstRepo, tags)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Copied %s/%s:%s to %s/%s@%s\n", src.Host, srcRepo, srcRef, dst.Host, dstRepo, digest)
+	return nil
+}
+
+// Execute the retag command
+func (x *RetagCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) != 3 {
+		return errors.New("Usage: k8ecr retag REPOSITORY SRC_TAG DST_TAG")
+	}
+	registry := ecr.NewRegistry()
+	digest, err := registry.Retag(args[0], args[1], args[2])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Tagged %s@%s as %s\n", args[0], digest, args[2])
+	return nil
+}
+
+func init() {
+	parser.AddCommand("copy",
+		"Copy",
+		"Copy an image between repositories, accounts or regions without pulling it",
+		&copyCommand)
+	parser.AddCommand("retag",
+		"Retag",
+		"Add a tag to an image in a repository",
+		&retagCommand)
+}
@@ -3,12 +3,69 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/isotoma/k8ecr/pkg/ecr"
+	"gopkg.in/yaml.v2"
 )
 
+// RepositoryFlags are the settings for new repositories
+type RepositoryFlags struct {
+	Config        string   `short:"c" long:"config" description:"YAML file of repository settings"`
+	ScanOnPush    bool     `long:"scan-on-push" description:"Scan images for vulnerabilities when they are pushed"`
+	ImmutableTags bool     `long:"immutable-tags" description:"Prevent tags from being overwritten"`
+	KMS           bool     `long:"kms" description:"Encrypt images with KMS rather than AES256"`
+	KMSKey        string   `long:"kms-key" description:"KMS key to encrypt images with, implies --kms"`
+	Tags          []string `long:"tag" description:"Resource tag as KEY=VALUE, may be repeated"`
+	Lifecycle     []string `long:"lifecycle" description:"Lifecycle template as NAME or NAME:N, may be repeated"`
+}
+
+// repositoryOptions reads the config file, if any, and applies the flags over it
+func (f *RepositoryFlags) repositoryOptions() (ecr.RepositoryOptions, error) {
+	options := ecr.RepositoryOptions{}
+	if f.Config != "" {
+		yamlFile, err := ioutil.ReadFile(f.Config)
+		if err != nil {
+			return options, err
+		}
+		if err := yaml.UnmarshalStrict(yamlFile, &options); err != nil {
+			return options, err
+		}
+	}
+	// Only settings that were asked for are changed on existing repositories
+	if f.ScanOnPush {
+		options.ScanOnPush = aws.Bool(true)
+	}
+	if f.ImmutableTags {
+		options.ImmutableTags = aws.Bool(true)
+	}
+	options.KMS = options.KMS || f.KMS
+	if f.KMSKey != "" {
+		options.KMSKey = f.KMSKey
+	}
+	if len(f.Lifecycle) > 0 {
+		options.Lifecycle = f.Lifecycle
+	}
+	if options.Tags == nil {
+		options.Tags = make(map[string]string)
+	}
+	for _, t := range f.Tags {
+		parts := strings.SplitN(t, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return options, fmt.Errorf("Tag %s is not KEY=VALUE", t)
+		}
+		options.Tags[parts[0]] = parts[1]
+	}
+	return options, nil
+}
+
 // CreateCommand is a create command
-type CreateCommand struct{}
+type CreateCommand struct {
+	ClusterOptions  `group:"Cluster Options"`
+	RepositoryFlags `group:"Repository Options"`
+}
 
 var createCommand CreateCommand
 
@@ -17,12 +74,25 @@ func (x *CreateCommand) Execute(args []string) error {
 	if len(args) == 0 {
 		return errors.New("No repository name specified")
 	}
+	processOptions()
+	options, err := x.repositoryOptions()
+	if err != nil {
+		return err
+	}
+	cluster, err := x.clusterName()
+	if err != nil {
+		return err
+	}
+	principals, err := x.principals()
+	if err != nil {
+		return err
+	}
 	registry := ecr.NewRegistry()
-	repository, err := registry.CreateRepository(args[0])
+	repository, err := registry.CreateRepository(args[0], options, cluster, principals)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Created repository %s\n", *repository.RepositoryUri)
+	fmt.Printf("Repository %s is ready\n", *repository.RepositoryUri)
 	return nil
 }
 
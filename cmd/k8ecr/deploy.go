@@ -7,18 +7,35 @@ import (
 
 	"github.com/gosuri/uitable"
 	"github.com/isotoma/k8ecr/pkg/apps"
+	"github.com/isotoma/k8ecr/pkg/cluster"
 	"github.com/isotoma/k8ecr/pkg/ecr"
 	"github.com/isotoma/k8ecr/pkg/resources"
 )
 
-// DeployCommand has no options
-type DeployCommand struct{}
+// DeployCommand has options controlling how images are written, and which
+// clusters they are deployed to
+type DeployCommand struct {
+	Pin              string   `long:"pin" choice:"tag" choice:"tag-digest" choice:"digest" default:"tag" description:"Write image references by tag, tag and digest, or digest alone"`
+	Contexts         []string `long:"context" description:"Kubeconfig context to deploy to, may be repeated"`
+	AllContexts      bool     `long:"all-contexts" description:"Deploy to every context in the kubeconfig"`
+	FailFast         bool     `long:"fail-fast" description:"Stop after the first cluster that fails to upgrade"`
+	ScanGateOptions  `group:"Scan Gate Options"`
+	SignatureOptions `group:"Signature Options"`
+	PlatformOptions  `group:"Platform Options"`
+}
 
 var deployCommand DeployCommand
 
+var pinModes = map[string]apps.PinMode{
+	"tag":        apps.PinNone,
+	"tag-digest": apps.PinTagDigest,
+	"digest":     apps.PinDigest,
+}
+
 func filter(registry *ecr.Registry, mgr *apps.AppManager) error {
 	for _, repo := range registry.GetRepositories() {
-		parts := strings.Split(repo.URI, "/")
+		parts := strings.SplitN(repo.URI, "/", 2)
+		mgr.SetDigests(parts[0], parts[1], repo.Digests)
 		mgr.SetLatest(parts[0], parts[1], repo.LatestTag)
 	}
 	return nil
@@ -28,26 +45,60 @@ func autodeploy(mgr *apps.AppManager) error {
 	return nil
 }
 
-func chooser(mgr *apps.AppManager) error {
+func contextName(mgr *apps.AppManager) string {
+	if mgr.Context == "" {
+		return "(current)"
+	}
+	return mgr.Context
+}
+
+func chooser(mgrs []*apps.AppManager, failFast bool) error {
 	table := uitable.New()
 	table.MaxColWidth = 120
 	cols := []interface{}{"APP", "IMAGE", "LATEST", "OLD VERSIONS"}
+	if len(mgrs) > 1 {
+		cols = append([]interface{}{"CONTEXT"}, cols...)
+	}
 	kinds := make([]string, 0)
-	for kind := range mgr.Managers {
+	for kind, rm := range mgrs[0].Managers {
+		if rm.History {
+			continue
+		}
 		kinds = append(kinds, kind)
 		cols = append(cols, fmt.Sprintf("%sS", strings.ToUpper(kind)))
 	}
+	blocked := false
+	for _, mgr := range mgrs {
+		for _, app := range mgr.Apps {
+			for _, cs := range app.GetChangeSets() {
+				if cs.NeedsUpdate && cs.Blocked != "" {
+					blocked = true
+				}
+			}
+		}
+	}
+	if blocked {
+		cols = append(cols, "BLOCKED")
+	}
 	options := 0
 	table.AddRow(cols...)
-	for _, app := range mgr.Apps {
-		for _, cs := range app.GetChangeSets() {
-			if cs.NeedsUpdate {
-				options++
-				row := []interface{}{app.Name, cs.ImageID.Repo, cs.UpdateTo, strings.Join(cs.Versions(), ", ")}
-				for _, kind := range kinds {
-					row = append(row, len(cs.Containers[kind]))
+	for _, mgr := range mgrs {
+		for _, app := range mgr.Apps {
+			for _, cs := range app.GetChangeSets() {
+				if cs.NeedsUpdate {
+					options++
+					row := []interface{}{app.Name, cs.ImageID.Repo, cs.UpdateTo, strings.Join(cs.Versions(), ", ")}
+					if len(mgrs) > 1 {
+						row = append([]interface{}{contextName(mgr)}, row...)
+					}
+					for _, kind := range kinds {
+						row = append(row, len(cs.Containers[kind]))
+					}
+					if blocked {
+						row = append(row, cs.Blocked)
+					}
+					table.AddRow(row...)
 				}
-				table.AddRow(row...)
 			}
 		}
 	}
@@ -59,36 +110,101 @@ func chooser(mgr *apps.AppManager) error {
 	var input string
 	fmt.Print("app? > ")
 	fmt.Scanln(&input)
-	app, ok := mgr.Apps[input]
+	if len(mgrs) == 1 {
+		_, err := upgradeApp(mgrs[0], input)
+		return err
+	}
+	return upgradeClusters(mgrs, input, failFast)
+}
+
+// Outcomes of upgradeApp
+const (
+	upgraded      = "ok"
+	upToDate      = "does not require update"
+	appNotPresent = "app not present"
+)
+
+// upgradeApp upgrades the first changeset of the named app that requires it,
+// and returns what it did
+func upgradeApp(mgr *apps.AppManager, name string) (string, error) {
+	app, ok := mgr.Apps[name]
 	if ok {
 		for _, cs := range app.GetChangeSets() {
 			if cs.NeedsUpdate {
-				return cs.Upgrade(mgr)
+				return upgraded, cs.Upgrade(mgr)
 			}
 		}
 		fmt.Printf("Does not require update.\n")
-		return nil
+		return upToDate, nil
 	}
 	fmt.Printf("App not known\n")
+	return appNotPresent, nil
+}
+
+// upgradeClusters upgrades the named app in every cluster and reports the
+// outcome for each, optionally stopping at the first failure
+func upgradeClusters(mgrs []*apps.AppManager, name string, failFast bool) error {
+	results := make([]string, 0)
+	failed := 0
+	for _, mgr := range mgrs {
+		if _, ok := mgr.Apps[name]; !ok {
+			results = append(results, fmt.Sprintf("%s: %s", contextName(mgr), appNotPresent))
+			continue
+		}
+		fmt.Printf("\n%s:\n", contextName(mgr))
+		status, err := upgradeApp(mgr, name)
+		if err == nil {
+			results = append(results, fmt.Sprintf("%s: %s", contextName(mgr), status))
+			continue
+		}
+		failed++
+		results = append(results, fmt.Sprintf("%s: failed: %s", contextName(mgr), err))
+		if failFast {
+			break
+		}
+	}
+	fmt.Printf("\n%s\n", strings.Join(results, "\n"))
+	if failed > 0 {
+		return fmt.Errorf("Upgrade failed in %d of %d clusters", failed, len(mgrs))
+	}
 	return nil
 }
 
-func deploy(namespace, image string) error {
+func deploy(contexts []string, namespace, image string, pin apps.PinMode, failFast bool, gate *ScanGateOptions, signatures *SignatureOptions, platforms *PlatformOptions) error {
 	registry := ecr.NewRegistry()
 	if err := registry.FetchAll(); err != nil {
 		return err
 	}
-	imagemgr, err := apps.NewAppManager(namespace)
-	filter(registry, imagemgr)
-	if err != nil {
+	mgrs := make([]*apps.AppManager, 0)
+	for _, context := range contexts {
+		imagemgr, err := apps.NewAppManagerForContext(context, namespace)
+		if err != nil {
+			return err
+		}
+		filter(registry, imagemgr)
+		imagemgr.Pin = pin
+		mgrs = append(mgrs, imagemgr)
+	}
+	if err := gate.checkScans(registry, mgrs, image != "-"); err != nil {
+		return err
+	}
+	if err := signatures.checkSignatures(mgrs); err != nil {
+		return err
+	}
+	if err := platforms.checkPlatforms(mgrs); err != nil {
 		return err
 	}
 
 	if image == "-" {
 		// Autodeploy
-		return autodeploy(imagemgr)
+		for _, imagemgr := range mgrs {
+			if err := autodeploy(imagemgr); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	return chooser(imagemgr)
+	return chooser(mgrs, failFast)
 }
 
 // Execute the deploy command
@@ -102,7 +218,18 @@ func (x *DeployCommand) Execute(args []string) error {
 	if len(args) == 2 {
 		image = args[1]
 	}
-	return deploy(namespace, image)
+	contexts := x.Contexts
+	if x.AllContexts {
+		var err error
+		contexts, err = cluster.Contexts()
+		if err != nil {
+			return err
+		}
+	}
+	if len(contexts) == 0 {
+		contexts = []string{""}
+	}
+	return deploy(contexts, namespace, image, pinModes[x.Pin], x.FailFast, &x.ScanGateOptions, &x.SignatureOptions, &x.PlatformOptions)
 }
 
 func init() {
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/isotoma/k8ecr/pkg/apps"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+	"gopkg.in/yaml.v2"
+)
+
+// ScanGateOptions refuse deploys of images whose scans have too many findings
+type ScanGateOptions struct {
+	BlockOn     []string      `long:"block-on" description:"Refuse to deploy images with more than MAX findings of SEVERITY, as SEVERITY[:MAX], may be repeated"`
+	ScanGate    string        `long:"scan-gate" description:"YAML file of thresholds and per-app CVE exceptions"`
+	ScanMissing bool          `long:"scan-missing" description:"Scan images that have not been scanned and wait for the results, without asking"`
+	ScanTimeout time.Duration `long:"scan-timeout" default:"10m" description:"How long to wait for a scan"`
+}
+
+// scanGate reads the gate file, if any, and adds the --block-on thresholds
+func (o *ScanGateOptions) scanGate() (*ecr.ScanGate, error) {
+	gate := &ecr.ScanGate{}
+	if o.ScanGate != "" {
+		data, err := ioutil.ReadFile(o.ScanGate)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, gate); err != nil {
+			return nil, fmt.Errorf("Cannot read %s: %s", o.ScanGate, err)
+		}
+	}
+	thresholds := make(map[string]int)
+	for severity, max := range gate.Thresholds {
+		s, _, err := ecr.ParseThreshold(severity)
+		if err != nil {
+			return nil, err
+		}
+		thresholds[s] = max
+	}
+	for _, t := range o.BlockOn {
+		severity, max, err := ecr.ParseThreshold(t)
+		if err != nil {
+			return nil, err
+		}
+		thresholds[severity] = max
+	}
+	gate.Thresholds = thresholds
+	return gate, nil
+}
+
+// checkScans blocks the changesets whose target image fails the gate.
+// Images that have not been scanned are scanned with --scan-missing, or if
+// the user agrees when interactive. Every scan is started before waiting for
+// any, so the wait is at most --scan-timeout however many there are.
+func (o *ScanGateOptions) checkScans(registry *ecr.Registry, mgrs []*apps.AppManager, interactive bool) error {
+	gate, err := o.scanGate()
+	if err != nil || !gate.IsSet() {
+		return err
+	}
+	names := make(map[string]string)
+	for _, repo := range registry.GetRepositories() {
+		names[repo.URI] = repo.Name
+	}
+	target := func(cs *apps.ChangeSet) (ecr.ImageReference, bool) {
+		name, ok := names[cs.ImageID.Registry+"/"+cs.ImageID.Repo]
+		reference := string(cs.UpdateTo)
+		if digest, found := cs.Digests[reference]; found && !strings.HasPrefix(reference, "sha256:") {
+			reference = digest
+		}
+		return ecr.ImageReference{Name: name, Reference: reference}, ok
+	}
+
+	scans := make(map[ecr.ImageReference]*ecr.ScanResult)
+	missing := make([]ecr.ImageReference, 0)
+	wait := make([]ecr.ImageReference, 0)
+	for _, mgr := range mgrs {
+		for _, app := range mgr.Apps {
+			for _, cs := range app.GetChangeSets() {
+				image, ok := target(cs)
+				if !cs.NeedsUpdate || !ok {
+					continue
+				}
+				if _, seen := scans[image]; seen {
+					continue
+				}
+				scan, err := registry.ScanFindings(image.Name, image.Reference)
+				if err != nil {
+					return err
+				}
+				scans[image] = scan
+				if scan == nil {
+					missing = append(missing, image)
+				} else if scan.Status == ecr.ScanInProgress && (o.ScanMissing || interactive) {
+					wait = append(wait, image)
+				}
+			}
+		}
+	}
+	if len(missing) > 0 {
+		list := make([]string, len(missing))
+		for i, image := range missing {
+			list[i] = image.String()
+		}
+		if o.ScanMissing || (interactive && confirm(fmt.Sprintf("Not scanned: %s. Scan them and wait?", strings.Join(list, ", ")))) {
+			for _, image := range missing {
+				if _, err := registry.StartScan(image.Name, image.Reference); err != nil {
+					return err
+				}
+			}
+			wait = append(wait, missing...)
+		}
+	}
+	if len(wait) > 0 {
+		fmt.Printf("Waiting for the scans of %d images\n", len(wait))
+		results, err := registry.WaitForScans(wait, o.ScanTimeout)
+		if err != nil {
+			return err
+		}
+		for image, scan := range results {
+			scans[image] = scan
+		}
+	}
+
+	check := func(app string, cs *apps.ChangeSet) (string, error) {
+		image, ok := target(cs)
+		if !ok {
+			return "", nil
+		}
+		return gate.Check(app, scans[image]), nil
+	}
+	for _, mgr := range mgrs {
+		if err := mgr.Block(check); err != nil {
+			return err
+		}
+	}
+	return nil
+}
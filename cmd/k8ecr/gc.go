@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/apps"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// GCCommand deletes images that nothing in the cluster refers to
+type GCCommand struct {
+	Contexts     []string `long:"context" description:"Kubeconfig context to scan, may be repeated"`
+	Repositories []string `short:"r" long:"repository" description:"Only collect from this repository, may be repeated"`
+	OlderThan    int      `long:"older-than" default:"30" description:"Only delete images pushed more than this many days ago"`
+	Keep         int      `long:"keep" default:"10" description:"Always keep this many of the newest images in each repository"`
+	Apply        bool     `long:"apply" description:"Delete the images, after the report"`
+	Yes          bool     `short:"y" long:"yes" description:"Delete without asking for confirmation"`
+}
+
+var gcCommand GCCommand
+
+// referencedImages returns the tags and digests referenced in every
+// namespace of every context, by registry and repository
+func referencedImages(contexts, namespaces []string) (map[apps.ImageIdentifier]map[string]bool, error) {
+	referenced := make(map[apps.ImageIdentifier]map[string]bool)
+	for _, context := range contexts {
+		for _, namespace := range namespaces {
+			mgr, err := apps.NewAppManagerForContext(context, namespace)
+			if err != nil {
+				return nil, err
+			}
+			if err := mgr.ScanHistory(); err != nil {
+				return nil, err
+			}
+			for _, c := range mgr.References() {
+				if _, ok := referenced[c.ImageID]; !ok {
+					referenced[c.ImageID] = make(map[string]bool)
+				}
+				referenced[c.ImageID][string(c.Current)] = true
+				if c.Digest != "" {
+					referenced[c.ImageID][c.Digest] = true
+				}
+			}
+		}
+	}
+	return referenced, nil
+}
+
+// Execute the gc command
+func (x *GCCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) == 0 {
+		return errors.New("Usage: k8ecr gc [--apply] NAMESPACE...")
+	}
+	contexts := x.Contexts
+	if len(contexts) == 0 {
+		contexts = []string{""}
+	}
+	referenced, err := referencedImages(contexts, args)
+	if err != nil {
+		return err
+	}
+	registry := ecr.NewRegistry()
+	if err := registry.FetchAll(); err != nil {
+		return err
+	}
+	only := make(map[string]bool)
+	for _, r := range x.Repositories {
+		only[r] = true
+	}
+	cutoff := time.Now().AddDate(0, 0, -x.OlderThan)
+	garbage := make(map[string][]ecr.Image)
+	table := uitable.New()
+	table.MaxColWidth = 80
+	table.AddRow("REPOSITORY", "DIGEST", "TAGS", "PUSHED")
+	total := 0
+	for _, repo := range registry.GetRepositories() {
+		if len(only) > 0 && !only[repo.Name] {
+			continue
+		}
+		images, err := registry.Images(repo.Name)
+		if err != nil {
+			return err
+		}
+		parts := strings.SplitN(repo.URI, "/", 2)
+		id := apps.ImageIdentifier{Registry: parts[0], Repo: parts[1]}
+		// The images of multi-platform lists are untagged, but cannot be
+		// deleted while a list refers to them
+		lists, err := registry.ManifestLists(repo.Name, images)
+		if err != nil {
+			return err
+		}
+		garbage[repo.Name] = ecr.SelectGarbage(images, referenced[id], lists, cutoff, x.Keep)
+		for _, image := range garbage[repo.Name] {
+			table.AddRow(repo.Name, image.Digest, strings.Join(image.Tags, ", "), image.PushedAt.Format("2006-01-02"))
+			total++
+		}
+	}
+	if total == 0 {
+		fmt.Println("No images to delete.")
+		return nil
+	}
+	fmt.Println(table)
+	fmt.Printf("\n%d images are unreferenced, older than %d days and not among the newest %d.\n", total, x.OlderThan, x.Keep)
+	if !x.Apply {
+		fmt.Println("This is a dry run, use --apply to delete them.")
+		return nil
+	}
+	if !x.Yes && !confirm(fmt.Sprintf("Delete %d images?", total)) {
+		return nil
+	}
+	for name, images := range garbage {
+		if len(images) == 0 {
+			continue
+		}
+		fmt.Printf("Deleting %d images from %s\n", len(images), name)
+		if err := registry.DeleteImages(name, images); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	parser.AddCommand("gc",
+		"Garbage collect",
+		"Delete old ECR images that are not used in your clusters",
+		&gcCommand)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// GrantCommand grants a cluster access to an existing repository
+type GrantCommand struct {
+	ClusterOptions `group:"Cluster Options"`
+}
+
+// RevokeCommand removes a cluster's access to a repository
+type RevokeCommand struct {
+	ClusterOptions `group:"Cluster Options"`
+}
+
+var grantCommand GrantCommand
+var revokeCommand RevokeCommand
+
+// Execute the grant command
+func (x *GrantCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) != 1 {
+		return errors.New("Usage: k8ecr grant REPOSITORY")
+	}
+	cluster, err := x.clusterName()
+	if err != nil {
+		return err
+	}
+	principals, err := x.principals()
+	if err != nil {
+		return err
+	}
+	registry := ecr.NewRegistry()
+	if err := registry.Grant(args[0], cluster, principals); err != nil {
+		return err
+	}
+	fmt.Printf("Granted %s access to %s\n", cluster, args[0])
+	return nil
+}
+
+// Execute the revoke command
+func (x *RevokeCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) != 1 {
+		return errors.New("Usage: k8ecr revoke REPOSITORY")
+	}
+	cluster, err := x.clusterName()
+	if err != nil {
+		return err
+	}
+	// The principals are only needed to remove access granted by the legacy
+	// cluster statement, so revoke what we can if they cannot be found
+	principals, err := x.principals()
+	if err != nil {
+		fmt.Printf("Warning: cannot find the principals of %s, so access through the legacy \"Cluster access\" statement is kept: %s\n", cluster, err)
+	}
+	registry := ecr.NewRegistry()
+	revoked, err := registry.Revoke(args[0], cluster, principals)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		fmt.Printf("Revoked %s access to %s\n", cluster, args[0])
+	} else {
+		fmt.Printf("%s had not been granted access to %s\n", cluster, args[0])
+	}
+	return nil
+}
+
+func init() {
+	parser.AddCommand("grant",
+		"Grant",
+		"Grant your cluster read permissions to an existing ECR repository",
+		&grantCommand)
+	parser.AddCommand("revoke",
+		"Revoke",
+		"Remove your cluster's read permissions from an ECR repository",
+		&revokeCommand)
+}
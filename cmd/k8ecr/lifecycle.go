@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// LifecycleCommand applies lifecycle policies built from templates
+type LifecycleCommand struct {
+	Templates []string `short:"t" long:"template" description:"Lifecycle template as NAME or NAME:N, may be repeated"`
+	Preview   bool     `short:"p" long:"preview" description:"Show the images the policy would expire without applying it"`
+	List      bool     `short:"l" long:"list" description:"List the available templates"`
+}
+
+var lifecycleCommand LifecycleCommand
+
+func listLifecycleTemplates() {
+	table := uitable.New()
+	table.AddRow("TEMPLATE", "DEFAULT N", "DESCRIPTION")
+	for _, t := range ecr.LifecycleTemplates {
+		table.AddRow(t.Name, t.Default, t.Description)
+	}
+	fmt.Println(table)
+}
+
+func previewLifecyclePolicy(registry *ecr.Registry, name, policy string) error {
+	fmt.Println("Waiting for ECR to preview the lifecycle policy...")
+	previews, err := registry.PreviewLifecyclePolicy(name, policy)
+	if err != nil {
+		return err
+	}
+	if len(previews) == 0 {
+		fmt.Println("No images would be expired.")
+		return nil
+	}
+	table := uitable.New()
+	table.MaxColWidth = 80
+	table.AddRow("DIGEST", "TAGS", "PUSHED", "ACTION", "RULE")
+	for _, p := range previews {
+		table.AddRow(p.Digest, strings.Join(p.Tags, ", "), p.PushedAt.Format("2006-01-02"), p.Action, p.Rule)
+	}
+	fmt.Println(table)
+	fmt.Printf("\n%d images would be expired.\n", len(previews))
+	return nil
+}
+
+// Execute the lifecycle command
+func (x *LifecycleCommand) Execute(args []string) error {
+	processOptions()
+	if x.List {
+		listLifecycleTemplates()
+		return nil
+	}
+	if len(args) != 1 || len(x.Templates) == 0 {
+		return errors.New("Usage: k8ecr lifecycle --template NAME[:N]... [--preview] REPOSITORY")
+	}
+	policy, err := ecr.LifecyclePolicy(x.Templates)
+	if err != nil {
+		return err
+	}
+	Verbose.Println("Lifecycle policy", policy)
+	registry := ecr.NewRegistry()
+	if x.Preview {
+		return previewLifecyclePolicy(registry, args[0], policy)
+	}
+	if err := registry.PutLifecyclePolicy(args[0], policy); err != nil {
+		return err
+	}
+	fmt.Printf("Applied lifecycle policy to %s\n", args[0])
+	return nil
+}
+
+func init() {
+	parser.AddCommand("lifecycle",
+		"Lifecycle",
+		"Apply a lifecycle policy to an ECR repository",
+		&lifecycleCommand)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// LoginCommand logs the Docker client in to ECR registries
+type LoginCommand struct {
+	Regions  []string `long:"region" description:"Region to log in to, may be repeated (default: the current region)"`
+	Accounts []string `long:"registry-id" description:"Account whose registry to log in to, may be repeated (default: the current account)"`
+	Profile  string   `long:"profile" description:"AWS profile to use instead of the default credentials"`
+	Helper   bool     `long:"helper" description:"Configure the registries to use docker-credential-k8ecr rather than writing credentials that expire"`
+	Config   string   `long:"config" description:"Docker config file (default: ~/.docker/config.json)"`
+}
+
+// CredentialHelperCommand answers Docker credential helper requests
+type CredentialHelperCommand struct{}
+
+var loginCommand LoginCommand
+var credentialHelperCommand CredentialHelperCommand
+
+// Execute the login command
+func (x *LoginCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) != 0 {
+		return errors.New("Usage: k8ecr login [--region REGION...] [--registry-id ACCOUNT...]")
+	}
+	path := x.Config
+	if path == "" {
+		path = ecr.DockerConfigPath()
+	}
+	regions := x.Regions
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+	creds := make([]*ecr.Credentials, 0)
+	for _, region := range regions {
+		c, err := ecr.Authorize(region, x.Profile, x.Accounts)
+		if err != nil {
+			return err
+		}
+		creds = append(creds, c...)
+	}
+	helper := ""
+	if x.Helper {
+		helper = "k8ecr"
+		// Prime the helper so the first pull does not need a token
+		cache := ecr.LoadCredentialCache(ecr.DefaultCachePath())
+		for _, c := range creds {
+			cache.Put(c.Host, c, time.Now())
+		}
+		if err := cache.Save(); err != nil {
+			return err
+		}
+	}
+	if err := ecr.WriteDockerConfig(path, creds, helper); err != nil {
+		return err
+	}
+	table := uitable.New()
+	if x.Helper {
+		table.AddRow("REGISTRY", "HELPER")
+		for _, c := range creds {
+			table.AddRow(c.Host, ecr.HelperName)
+		}
+	} else {
+		table.AddRow("REGISTRY", "EXPIRES")
+		for _, c := range creds {
+			table.AddRow(c.Host, c.ExpiresAt.Local().Format(time.RFC1123))
+		}
+	}
+	fmt.Println(table)
+	fmt.Println("Updated", path)
+	return nil
+}
+
+// Execute the credential-helper command
+func (x *CredentialHelperCommand) Execute(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: k8ecr credential-helper get|store|erase|list")
+	}
+	return ecr.NewCredentialHelper().Run(args[0], os.Stdin, os.Stdout)
+}
+
+// credentialHelper runs as docker-credential-k8ecr, when the binary is
+// installed under that name. Errors go to stdout for the Docker client.
+func credentialHelper() {
+	if len(os.Args) != 2 {
+		fmt.Println("Usage:", ecr.HelperName, "get|store|erase|list")
+		os.Exit(1)
+	}
+	if err := ecr.NewCredentialHelper().Run(os.Args[1], os.Stdin, os.Stdout); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	parser.AddCommand("login",
+		"Login",
+		"Log the Docker client in to ECR registries",
+		&loginCommand)
+	parser.AddCommand("credential-helper",
+		"Credential helper",
+		"Answer Docker credential helper requests, as docker-credential-k8ecr does",
+		&credentialHelperCommand)
+}
@@ -4,7 +4,9 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 
+	"github.com/isotoma/k8ecr/pkg/ecr"
 	"github.com/jessevdk/go-flags"
 	"gopkg.in/yaml.v2"
 )
@@ -48,6 +50,10 @@ func processOptions() {
 }
 
 func main() {
+	if filepath.Base(os.Args[0]) == ecr.HelperName {
+		credentialHelper()
+		return
+	}
 	_, err := parser.Parse()
 	if err != nil {
 		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
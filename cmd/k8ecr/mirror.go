@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/isotoma/k8ecr/pkg/ecr"
+	"github.com/isotoma/k8ecr/pkg/registry"
+)
+
+// MirrorCommand copies images from other registries into ECR
+type MirrorCommand struct {
+	ClusterOptions  `group:"Cluster Options"`
+	RepositoryFlags `group:"Repository Options"`
+	Repo            string `long:"repo" description:"ECR repository to mirror into, by default the image name without library/"`
+	Sync            string `long:"sync" description:"Also copy every tag matching this pattern, such as '7.*', that ECR does not have"`
+	SourceUsername  string `long:"source-username" env:"K8ECR_SOURCE_USERNAME" description:"Username for the source registry"`
+	SourcePassword  string `long:"source-password" env:"K8ECR_SOURCE_PASSWORD" description:"Password for the source registry"`
+}
+
+var mirrorCommand MirrorCommand
+
+// mirrorRepository is the ECR repository an image is mirrored into by default
+func mirrorRepository(repo string) string {
+	return strings.TrimPrefix(repo, "library/")
+}
+
+// Execute the mirror command
+func (x *MirrorCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) != 1 {
+		return errors.New("Usage: k8ecr mirror [--repo REPOSITORY] [--sync PATTERN] IMAGE[:TAG]")
+	}
+	host, repo, reference := ecr.ParseReference(args[0])
+	host, repo = registry.Normalize(host, repo)
+	if reference == "" && x.Sync == "" {
+		reference = "latest"
+	}
+	target := x.Repo
+	if target == "" {
+		target = mirrorRepository(repo)
+	}
+
+	options, err := x.repositoryOptions()
+	if err != nil {
+		return err
+	}
+	cluster, err := x.clusterName()
+	if err != nil {
+		return err
+	}
+	principals, err := x.principals()
+	if err != nil {
+		return err
+	}
+	if _, err := ecr.NewRegistry().CreateRepository(target, options, cluster, principals); err != nil {
+		return err
+	}
+
+	src := registry.New(host, x.SourceUsername, x.SourcePassword)
+	dst, err := ecr.ClientFor("", "")
+	if err != nil {
+		return err
+	}
+	dst.Progress = printProgress
+	references := make([]string, 0)
+	if reference != "" {
+		references = append(references, reference)
+	}
+	if x.Sync != "" {
+		sourceTags, err := src.Tags(repo)
+		if err != nil {
+			return err
+		}
+		existing, err := dst.Tags(target)
+		if err != nil {
+			return err
+		}
+		missing, err := registry.MissingTags(sourceTags, existing, x.Sync)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d tags of %s/%s matching %s are not in ECR\n", len(missing), host, repo, x.Sync)
+		for _, t := range missing {
+			if t != reference {
+				references = append(references, t)
+			}
+		}
+	}
+	for _, ref := range references {
+		tags := []string{ref}
+		if strings.HasPrefix(ref, "sha256:") {
+			tags = nil
+		}
+		fmt.Printf("Mirroring %s/%s:%s\n", host, repo, ref)
+		digest, err := registry.Copy(src, repo, ref, dst, target, tags)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Mirrored to %s/%s:%s@%s\n", dst.Host, target, ref, digest)
+	}
+	return nil
+}
+
+func init() {
+	parser.AddCommand("mirror",
+		"Mirror",
+		"Copy an image from another registry, such as Docker Hub, into ECR",
+		&mirrorCommand)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/isotoma/k8ecr/pkg/apps"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+	"github.com/isotoma/k8ecr/pkg/registry"
+)
+
+// PlatformOptions check images run on the nodes their workloads can use
+type PlatformOptions struct {
+	PlatformCheck string `long:"platform-check" choice:"off" choice:"warn" choice:"block" default:"warn" description:"Warn about, or refuse, images without a platform for every node their workloads can be scheduled on"`
+}
+
+// checkPlatforms compares the platforms of the image each changeset would
+// upgrade to with those of the nodes its workloads can be scheduled on. When
+// only warning, nodes or platforms that cannot be read are warned about too.
+func (o *PlatformOptions) checkPlatforms(mgrs []*apps.AppManager) error {
+	if o.PlatformCheck == "off" {
+		return nil
+	}
+	clients := make(map[string]*registry.Client)
+	images := make(map[string][]string)
+	failures := make(map[string]error)
+	platforms := func(cs *apps.ChangeSet) ([]string, error) {
+		reference := string(cs.UpdateTo)
+		if digest, ok := cs.Digests[reference]; ok {
+			reference = digest
+		}
+		key := cs.ImageID.Registry + "/" + cs.ImageID.Repo + "@" + reference
+		if p, ok := images[key]; ok {
+			return p, nil
+		}
+		if err, ok := failures[cs.ImageID.Registry]; ok {
+			return nil, err
+		}
+		client, ok := clients[cs.ImageID.Registry]
+		if !ok {
+			var err error
+			if client, err = ecr.ClientFor(cs.ImageID.Registry, ""); err != nil {
+				// Do not try to log in to the registry again
+				failures[cs.ImageID.Registry] = err
+				return nil, err
+			}
+			clients[cs.ImageID.Registry] = client
+		}
+		found, err := client.Platforms(cs.ImageID.Repo, reference)
+		if err != nil {
+			return nil, err
+		}
+		p := make([]string, len(found))
+		for i, f := range found {
+			// Node labels do not give a variant
+			p[i] = f.OS + "/" + f.Architecture
+		}
+		images[key] = p
+		return p, nil
+	}
+	for _, mgr := range mgrs {
+		nodes, err := mgr.Nodes()
+		if err != nil {
+			if o.PlatformCheck == "block" {
+				return err
+			}
+			fmt.Printf("Warning: cannot list the nodes of %s, so platforms are not checked: %s\n", contextName(mgr), err)
+			continue
+		}
+		check := func(app string, cs *apps.ChangeSet) (string, error) {
+			p, err := platforms(cs)
+			if err != nil && o.PlatformCheck == "warn" {
+				fmt.Printf("Warning: cannot read the platforms of %s:%s, so they are not checked: %s\n", cs.ImageID.Repo, cs.UpdateTo, err)
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+			problems := cs.PlatformMismatches(nodes, p)
+			if len(problems) == 0 {
+				return "", nil
+			}
+			reason := fmt.Sprintf("image is %s only, but %s", strings.Join(p, ", "), strings.Join(problems, ", "))
+			if o.PlatformCheck == "warn" {
+				fmt.Printf("Warning: %s %s:%s: %s\n", app, cs.ImageID.Repo, cs.UpdateTo, reason)
+				return "", nil
+			}
+			return reason, nil
+		}
+		if err := mgr.Block(check); err != nil {
+			return err
+		}
+	}
+	return nil
+}
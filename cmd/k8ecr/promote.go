@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/apps"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// PromoteCommand deploys the versions running in one namespace to another
+type PromoteCommand struct {
+	Pin              string `long:"pin" choice:"tag" choice:"tag-digest" choice:"digest" default:"tag" description:"Write image references by tag, tag and digest, or digest alone"`
+	Yes              bool   `short:"y" long:"yes" description:"Apply without asking for confirmation"`
+	ScanGateOptions  `group:"Scan Gate Options"`
+	SignatureOptions `group:"Signature Options"`
+	PlatformOptions  `group:"Platform Options"`
+}
+
+var promoteCommand PromoteCommand
+
+func confirm(prompt string) bool {
+	var input string
+	fmt.Printf("%s [y/N] > ", prompt)
+	fmt.Scanln(&input)
+	return strings.ToLower(input) == "y"
+}
+
+func promote(source, target string, names []string, pin apps.PinMode, yes bool, gate *ScanGateOptions, signatures *SignatureOptions, platforms *PlatformOptions) error {
+	registry := ecr.NewRegistry()
+	if err := registry.FetchAll(); err != nil {
+		return err
+	}
+	sourcemgr, err := apps.NewAppManager(source)
+	if err != nil {
+		return err
+	}
+	targetmgr, err := apps.NewAppManager(target)
+	if err != nil {
+		return err
+	}
+	targetmgr.Pin = pin
+	for _, repo := range registry.GetRepositories() {
+		parts := strings.SplitN(repo.URI, "/", 2)
+		sourcemgr.SetDigests(parts[0], parts[1], repo.Digests)
+		targetmgr.SetDigests(parts[0], parts[1], repo.Digests)
+	}
+	promotions, err := targetmgr.PromoteFrom(sourcemgr, names)
+	if err != nil {
+		return err
+	}
+	if len(promotions) == 0 {
+		fmt.Printf("%s already runs the same versions as %s.\n", target, source)
+		return nil
+	}
+	if err := gate.checkScans(registry, []*apps.AppManager{targetmgr}, !yes); err != nil {
+		return err
+	}
+	if err := signatures.checkSignatures([]*apps.AppManager{targetmgr}); err != nil {
+		return err
+	}
+	if err := platforms.checkPlatforms([]*apps.AppManager{targetmgr}); err != nil {
+		return err
+	}
+	table := uitable.New()
+	table.MaxColWidth = 120
+	blocked := 0
+	for _, p := range promotions {
+		if p.ChangeSet.Blocked != "" {
+			blocked++
+		}
+	}
+	if blocked > 0 {
+		table.AddRow("APP", "IMAGE", "FROM", "TO", "BLOCKED")
+	} else {
+		table.AddRow("APP", "IMAGE", "FROM", "TO")
+	}
+	for _, p := range promotions {
+		row := []interface{}{p.App, p.ChangeSet.ImageID.Repo, strings.Join(p.ChangeSet.Versions(), ", "), p.ChangeSet.UpdateTo}
+		if blocked > 0 {
+			row = append(row, p.ChangeSet.Blocked)
+		}
+		table.AddRow(row...)
+	}
+	fmt.Println(table)
+	if blocked == len(promotions) {
+		return errors.New("Every promotion is blocked")
+	}
+	if !yes && !confirm(fmt.Sprintf("Promote from %s to %s?", source, target)) {
+		return nil
+	}
+	for _, p := range promotions {
+		if p.ChangeSet.Blocked != "" {
+			continue
+		}
+		if err := p.ChangeSet.Upgrade(targetmgr); err != nil {
+			return err
+		}
+	}
+	if blocked > 0 {
+		return fmt.Errorf("%d of %d promotions were blocked", blocked, len(promotions))
+	}
+	return nil
+}
+
+// Execute the promote command
+func (x *PromoteCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) < 2 {
+		return errors.New("Usage: k8ecr promote SOURCE_NAMESPACE TARGET_NAMESPACE [APP...]")
+	}
+	return promote(args[0], args[1], args[2:], pinModes[x.Pin], x.Yes, &x.ScanGateOptions, &x.SignatureOptions, &x.PlatformOptions)
+}
+
+func init() {
+	parser.AddCommand(
+		"promote",
+		"Promote",
+		"Deploy the versions running in one namespace to another",
+		&promoteCommand)
+}
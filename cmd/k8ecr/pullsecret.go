@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/cluster"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// PullSecretCommand groups the pull-secret subcommands
+type PullSecretCommand struct{}
+
+// PullSecretSyncCommand keeps ECR pull secrets up to date in namespaces
+type PullSecretSyncCommand struct {
+	Context         string        `long:"context" description:"Kubeconfig context of the cluster, instead of the current context"`
+	Name            string        `long:"name" default:"k8ecr-pull-secret" description:"Name of the secret"`
+	ServiceAccounts []string      `long:"service-account" description:"Add the secret to the imagePullSecrets of this service account in each namespace, may be repeated"`
+	Regions         []string      `long:"region" description:"Region of a registry to include, may be repeated (default: the current region)"`
+	Accounts        []string      `long:"registry-id" description:"Account of a registry to include, may be repeated (default: the current account)"`
+	Every           time.Duration `long:"every" description:"Keep running, syncing at this interval, such as 6h"`
+	Output          string        `short:"o" long:"output" choice:"table" choice:"json" default:"table" description:"Output format"`
+}
+
+var pullSecretCommand PullSecretCommand
+var pullSecretSyncCommand PullSecretSyncCommand
+
+// maxSyncInterval leaves time to retry before ECR tokens expire after 12 hours
+const maxSyncInterval = 11 * time.Hour
+
+// sync mints a token for the registries and writes it to every namespace,
+// carrying on past namespaces that fail so the others do not expire
+func (x *PullSecretSyncCommand) sync(namespaces []string) ([]*cluster.PullSecretChange, error) {
+	regions := x.Regions
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+	auths := make([]cluster.RegistryAuth, 0)
+	var expires time.Time
+	for _, region := range regions {
+		creds, err := ecr.Authorize(region, "", x.Accounts)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range creds {
+			auths = append(auths, cluster.RegistryAuth{Host: c.Host, Username: c.Username, Password: c.Password})
+			if expires.IsZero() || c.ExpiresAt.Before(expires) {
+				expires = c.ExpiresAt
+			}
+		}
+	}
+	config, err := cluster.DockerConfigJSON(auths)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := cluster.GetClientSet(x.Context)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*cluster.PullSecretChange, 0, len(namespaces))
+	failed := 0
+	for _, ns := range namespaces {
+		change, err := cluster.SyncPullSecret(clientset, ns, x.Name, config, expires, x.ServiceAccounts)
+		if err != nil {
+			failed++
+			change = &cluster.PullSecretChange{
+				Namespace:       ns,
+				Action:          cluster.SecretFailed,
+				ServiceAccounts: make([]string, 0),
+				Error:           err.Error(),
+			}
+		}
+		changes = append(changes, change)
+	}
+	if failed > 0 {
+		return changes, fmt.Errorf("Pull secret sync failed in %d of %d namespaces", failed, len(namespaces))
+	}
+	return changes, nil
+}
+
+func (x *PullSecretSyncCommand) print(changes []*cluster.PullSecretChange) error {
+	if x.Output == "json" {
+		return json.NewEncoder(os.Stdout).Encode(changes)
+	}
+	table := uitable.New()
+	table.AddRow("NAMESPACE", "SECRET", "SERVICE ACCOUNTS ADDED")
+	for _, c := range changes {
+		action := fmt.Sprintf("%s %s", x.Name, c.Action)
+		if c.Error != "" {
+			action += ": " + c.Error
+		}
+		table.AddRow(c.Namespace, action, strings.Join(c.ServiceAccounts, ", "))
+	}
+	fmt.Println(table)
+	return nil
+}
+
+// Execute the pull-secret sync command
+func (x *PullSecretSyncCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) < 1 {
+		return errors.New("Usage: k8ecr pull-secret sync NAMESPACE...")
+	}
+	if x.Every < 0 || x.Every > maxSyncInterval {
+		return fmt.Errorf("--every must be at most %s, as ECR tokens expire after 12 hours", maxSyncInterval)
+	}
+	for {
+		changes, err := x.sync(args)
+		if len(changes) > 0 {
+			if printErr := x.print(changes); printErr != nil {
+				return printErr
+			}
+		}
+		if x.Every == 0 {
+			return err
+		}
+		if err != nil {
+			// Keep running, the next sync may succeed before the tokens expire
+			fmt.Fprintln(os.Stderr, err)
+		}
+		time.Sleep(x.Every)
+	}
+}
+
+func init() {
+	pullSecret, _ := parser.AddCommand("pull-secret",
+		"Manage pull secrets",
+		"Manage ECR pull secrets for clusters whose nodes cannot pull from ECR themselves",
+		&pullSecretCommand)
+	pullSecret.AddCommand("sync",
+		"Sync pull secrets",
+		"Create or update ECR pull secrets in namespaces before their tokens expire",
+		&pullSecretSyncCommand)
+}
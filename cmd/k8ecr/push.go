@@ -1,24 +1,202 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 
+	"github.com/gosuri/uitable"
 	"github.com/isotoma/k8ecr/pkg/ecr"
 )
 
 // PushCommand is the push command
-type PushCommand struct{}
+type PushCommand struct {
+	ClusterOptions  `group:"Cluster Options"`
+	RepositoryFlags `group:"Repository Options"`
+	From            string `long:"from" description:"Push from oci:DIR[:REF] or docker-archive:FILE instead of the Docker daemon"`
+	Jobs            int    `short:"j" long:"jobs" default:"4" description:"Number of images to push at once"`
+	Progress        string `long:"progress" choice:"tty" choice:"plain" choice:"json" default:"tty" description:"Show progress by redrawing lines, or as plain or JSON lines for CI logs"`
+	Create          bool   `long:"create" description:"Create repositories that do not exist, granting this cluster access"`
+	Source          string `long:"source" description:"Local image to push, by default REPOSITORY:TAG, or REPOSITORY:latest if no tag is given"`
+	SemverBump      string `long:"semver-bump" choice:"patch" choice:"minor" choice:"major" description:"Also tag the next version after the highest in the repository"`
+	GitSHA          bool   `long:"git-sha" description:"Also tag the commit of the git checkout"`
+	Alias           bool   `long:"alias" description:"Also tag MAJOR and MAJOR.MINOR of each version, unless a newer version has them"`
+}
 
 var pushCommand PushCommand
 
+func (x *PushCommand) strategy() ecr.TagStrategy {
+	return ecr.TagStrategy{Bump: x.SemverBump, GitSHA: x.GitSHA, Alias: x.Alias}
+}
+
+// pushTargets reads either REPOSITORY VERSION... or REPOSITORY:TAG... and
+// returns the repositories in order with their tags. With a tag strategy a
+// repository may be given alone.
+func pushTargets(args []string, strategy bool) ([]string, map[string][]string, error) {
+	repos := make([]string, 0)
+	tags := make(map[string][]string)
+	add := func(repo, tag string) {
+		if _, ok := tags[repo]; !ok {
+			repos = append(repos, repo)
+			tags[repo] = make([]string, 0)
+		}
+		if tag != "" {
+			tags[repo] = append(tags[repo], tag)
+		}
+	}
+	qualified := len(args) > 0
+	for _, arg := range args {
+		if !strings.Contains(arg, ":") {
+			qualified = false
+		}
+	}
+	if qualified {
+		for _, arg := range args {
+			i := strings.LastIndex(arg, ":")
+			add(arg[:i], arg[i+1:])
+		}
+		return repos, tags, nil
+	}
+	if len(args) == 0 || (len(args) < 2 && !strategy) {
+		return nil, nil, errors.New("Usage: k8ecr push REPOSITORY VERSION... or k8ecr push REPOSITORY:TAG...")
+	}
+	add(args[0], "")
+	for _, v := range args[1:] {
+		if strings.Contains(v, ":") {
+			return nil, nil, fmt.Errorf("Version %s cannot contain a colon", v)
+		}
+		add(args[0], v)
+	}
+	return repos, tags, nil
+}
+
+func printPushResults(results []ecr.PushResult, mode string) error {
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+	if mode == ecr.ProgressJSON {
+		summary := struct {
+			Summary []ecr.PushResult `json:"summary"`
+		}{results}
+		if err := json.NewEncoder(os.Stdout).Encode(&summary); err != nil {
+			return err
+		}
+	} else {
+		table := uitable.New()
+		table.AddRow("REFERENCE", "DIGEST")
+		for _, r := range results {
+			if r.Error != "" {
+				table.AddRow(r.Reference, "FAILED: "+r.Error)
+			} else {
+				table.AddRow(r.Reference, r.Digest)
+			}
+		}
+		fmt.Println()
+		fmt.Println(table)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pushes failed", failed, len(results))
+	}
+	return nil
+}
+
+// createMissing creates the repositories that do not exist yet. Existing
+// repositories are left as they are.
+func (x *PushCommand) createMissing(registry *ecr.Registry, repos []string) error {
+	options, err := x.repositoryOptions()
+	if err != nil {
+		return err
+	}
+	cluster, err := x.clusterName()
+	if err != nil {
+		return err
+	}
+	principals, err := x.principals()
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		exists, err := registry.Exists(repo)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := registry.CreateRepository(repo, options, cluster, principals); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Created repository %s\n", repo)
+	}
+	return nil
+}
+
 // Execute the push command
 func (x *PushCommand) Execute(args []string) error {
-	if len(args) < 2 {
-		return errors.New("push REPOSITORY VERSION")
+	processOptions()
+	strategy := x.strategy()
+	repos, explicit, err := pushTargets(args, strategy.IsSet())
+	if err != nil {
+		return err
 	}
 	registry := ecr.NewRegistry()
-	return registry.PushRepository(args[0], args[1:])
+	if x.Create {
+		if err := x.createMissing(registry, repos); err != nil {
+			return err
+		}
+	}
+	sha := ""
+	if x.GitSHA {
+		if sha, err = ecr.GitSHA(); err != nil {
+			return err
+		}
+	}
+	targets := make([]ecr.PushTarget, 0)
+	for _, repo := range repos {
+		tags := explicit[repo]
+		source := x.Source
+		if strategy.IsSet() {
+			existing, err := registry.Tags(repo)
+			if err != nil {
+				return err
+			}
+			if tags, err = strategy.Tags(tags, existing, sha); err != nil {
+				return err
+			}
+			if source == "" && len(explicit[repo]) > 0 {
+				source = repo + ":" + explicit[repo][0]
+			} else if source == "" {
+				source = repo + ":latest"
+			}
+		}
+		for _, tag := range tags {
+			targets = append(targets, ecr.PushTarget{Repo: repo, Tag: tag, Source: source})
+		}
+	}
 
+	progress := ecr.NewProgress(x.Progress, os.Stdout)
+	var results []ecr.PushResult
+	if x.From != "" {
+		if len(repos) != 1 {
+			return errors.New("Only one repository can be pushed --from a file")
+		}
+		versions := make([]string, len(targets))
+		for i, t := range targets {
+			versions[i] = t.Tag
+		}
+		results, err = registry.PushArchive(repos[0], x.From, versions, progress)
+	} else {
+		results, err = registry.PushImages(targets, x.Jobs, progress)
+	}
+	if err != nil {
+		return err
+	}
+	return printPushResults(results, x.Progress)
 }
 
 func init() {
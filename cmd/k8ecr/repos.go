@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+	"gopkg.in/yaml.v2"
+)
+
+// ReposCommand groups the commands that manage many repositories at once
+type ReposCommand struct{}
+
+// ReposSyncCommand makes ECR match a file of repositories
+type ReposSyncCommand struct {
+	File  string `short:"f" long:"file" required:"true" description:"YAML file declaring the repositories"`
+	Prune bool   `long:"prune" description:"Delete repositories, and their images, that are not declared"`
+	Yes   bool   `short:"y" long:"yes" description:"Apply without asking for confirmation"`
+}
+
+// grantConfig identifies a cluster in the same way as ClusterOptions. With
+// neither cluster nor context, the current context is used.
+type grantConfig struct {
+	Cluster    string   `yaml:"cluster"`
+	Context    string   `yaml:"context"`
+	Principals []string `yaml:"principals"`
+}
+
+type repositoryConfig struct {
+	Name                  string `yaml:"name"`
+	ecr.RepositoryOptions `yaml:",inline"`
+	Grants                []grantConfig `yaml:"grants"`
+}
+
+type reposConfig struct {
+	Repositories []repositoryConfig `yaml:"repositories"`
+}
+
+var reposCommand ReposCommand
+var reposSyncCommand ReposSyncCommand
+
+// readRepos reads the file and finds the principals of every cluster granted access
+func readRepos(filename string) ([]ecr.RepositorySpec, error) {
+	yamlFile, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := reposConfig{}
+	if err := yaml.UnmarshalStrict(yamlFile, &config); err != nil {
+		return nil, err
+	}
+	// Finding the roles of a cluster is slow, so each is only looked up once
+	resolved := make(map[[2]string]ecr.ClusterGrant)
+	specs := make([]ecr.RepositorySpec, len(config.Repositories))
+	for i, repo := range config.Repositories {
+		if repo.Name == "" {
+			return nil, fmt.Errorf("Repository %d in %s has no name", i+1, filename)
+		}
+		specs[i] = ecr.RepositorySpec{Name: repo.Name, Options: repo.RepositoryOptions}
+		for _, g := range repo.Grants {
+			key := [2]string{g.Cluster, g.Context}
+			grant, ok := resolved[key]
+			if !ok || len(g.Principals) > 0 {
+				options := ClusterOptions{Context: g.Context, Cluster: g.Cluster, Principals: g.Principals}
+				if grant.Cluster, err = options.clusterName(); err != nil {
+					return nil, err
+				}
+				if grant.Principals, err = options.principals(); err != nil {
+					return nil, err
+				}
+				if len(g.Principals) == 0 {
+					resolved[key] = grant
+				}
+			}
+			specs[i].Grants = append(specs[i].Grants, grant)
+		}
+	}
+	return specs, nil
+}
+
+// Execute the repos sync command
+func (x *ReposSyncCommand) Execute(args []string) error {
+	processOptions()
+	specs, err := readRepos(x.File)
+	if err != nil {
+		return err
+	}
+	registry := ecr.NewRegistry()
+	states, err := registry.RepositoryStates()
+	if err != nil {
+		return err
+	}
+	changes, warnings, err := ecr.PlanSync(specs, states, x.Prune)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+	if len(changes) == 0 {
+		fmt.Println("All repositories are in sync.")
+		return nil
+	}
+	table := uitable.New()
+	table.AddRow("REPOSITORY", "ACTION", "CHANGES")
+	for _, change := range changes {
+		table.AddRow(change.Repository, change.Action, strings.Join(change.Reasons, ", "))
+	}
+	fmt.Println(table)
+	if !x.Yes && !confirm(fmt.Sprintf("Apply %d changes?", len(changes))) {
+		return nil
+	}
+	for _, change := range changes {
+		fmt.Printf("Applying %s to %s\n", change.Action, change.Repository)
+		if err := registry.ApplySync(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	repos, _ := parser.AddCommand("repos",
+		"Manage repositories",
+		"Manage many repositories at once",
+		&reposCommand)
+	repos.AddCommand("sync",
+		"Sync repositories",
+		"Create, converge and optionally delete repositories to match a YAML file",
+		&reposSyncCommand)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/apps"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// ScanCommand scans images and reports their findings
+type ScanCommand struct {
+	Context    string        `long:"context" description:"Kubeconfig context of the namespaces, instead of the current context"`
+	Namespaces []string      `short:"n" long:"namespace" description:"Scan every ECR image deployed in the namespace, may be repeated"`
+	Output     string        `short:"o" long:"output" choice:"table" choice:"json" choice:"sarif" default:"table" description:"Output format"`
+	Timeout    time.Duration `long:"timeout" default:"10m" description:"How long to wait for each scan"`
+}
+
+var scanCommand ScanCommand
+
+// scanTarget is an image to scan and the apps that run it
+type scanTarget struct {
+	name      string
+	reference string
+	apps      []string
+}
+
+func (t *scanTarget) image() string {
+	if strings.HasPrefix(t.reference, "sha256:") {
+		return t.name + "@" + t.reference
+	}
+	return t.name + ":" + t.reference
+}
+
+func (t *scanTarget) addApp(app string) {
+	for _, a := range t.apps {
+		if a == app {
+			return
+		}
+	}
+	t.apps = append(t.apps, app)
+}
+
+// argTargets reads REPOSITORY[:TAG] arguments, scanning the latest version
+// if no tag is given
+func argTargets(registry *ecr.Registry, args []string) ([]*scanTarget, error) {
+	targets := make([]*scanTarget, 0)
+	for _, arg := range args {
+		host, name, reference := ecr.ParseReference(arg)
+		if host != "" {
+			return nil, fmt.Errorf("%s: only repositories in the current registry can be scanned", arg)
+		}
+		if reference == "" {
+			var err error
+			if reference, err = registry.Latest(name); err != nil {
+				return nil, err
+			}
+		}
+		targets = append(targets, &scanTarget{name: name, reference: reference})
+	}
+	return targets, nil
+}
+
+// namespaceTargets finds the ECR images deployed in the namespaces, by the
+// digest they are pinned to if they are
+func (x *ScanCommand) namespaceTargets(registry *ecr.Registry) ([]*scanTarget, error) {
+	if err := registry.FetchAll(); err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for _, repo := range registry.GetRepositories() {
+		names[repo.URI] = repo.Name
+	}
+	targets := make([]*scanTarget, 0)
+	byImage := make(map[string]*scanTarget)
+	for _, namespace := range x.Namespaces {
+		mgr, err := apps.NewAppManagerForContext(x.Context, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, app := range mgr.Apps {
+			for _, cs := range app.GetChangeSets() {
+				name, ok := names[cs.ImageID.Registry+"/"+cs.ImageID.Repo]
+				if !ok {
+					fmt.Fprintf(os.Stderr, "Skipping %s/%s, which is not in this registry\n", cs.ImageID.Registry, cs.ImageID.Repo)
+					continue
+				}
+				for _, containers := range cs.Containers {
+					for _, c := range containers {
+						reference := string(c.Current)
+						if c.Digest != "" {
+							reference = c.Digest
+						}
+						t, ok := byImage[name+" "+reference]
+						if !ok {
+							t = &scanTarget{name: name, reference: reference}
+							byImage[name+" "+reference] = t
+							targets = append(targets, t)
+						}
+						t.addApp(namespace + "/" + app.Name)
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].image() < targets[j].image() })
+	return targets, nil
+}
+
+func printScanTable(reports []ecr.ScanReport) {
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		header := fmt.Sprintf("%s (%s)", report.Image, report.Scan.Digest)
+		if len(report.Apps) > 0 {
+			header += " used by " + strings.Join(report.Apps, ", ")
+		}
+		fmt.Println(header)
+		if report.Scan.Status != ecr.ScanComplete {
+			fmt.Printf("Scan %s: %s\n", strings.ToLower(report.Scan.Status), report.Scan.Description)
+			continue
+		}
+		if len(report.Scan.Vulnerabilities) == 0 {
+			fmt.Println("No findings.")
+			continue
+		}
+		counts := report.Scan.Counts(nil)
+		summary := make([]string, 0)
+		for _, severity := range ecr.Severities {
+			if counts[severity] > 0 {
+				summary = append(summary, fmt.Sprintf("%d %s", counts[severity], severity))
+			}
+		}
+		fmt.Println(strings.Join(summary, ", "))
+		table := uitable.New()
+		table.MaxColWidth = 80
+		table.AddRow("SEVERITY", "CVE", "PACKAGE", "VERSION", "LINK")
+		for _, v := range report.Scan.Vulnerabilities {
+			table.AddRow(v.Severity, v.Name, v.Package, v.Version, v.URI)
+		}
+		fmt.Println(table)
+	}
+}
+
+// Execute the scan command
+func (x *ScanCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) == 0 && len(x.Namespaces) == 0 {
+		return errors.New("Usage: k8ecr scan REPOSITORY[:TAG]... or k8ecr scan --namespace NAMESPACE...")
+	}
+	registry := ecr.NewRegistry()
+	targets, err := argTargets(registry, args)
+	if err != nil {
+		return err
+	}
+	if len(x.Namespaces) > 0 {
+		deployed, err := x.namespaceTargets(registry)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, deployed...)
+	}
+
+	// Start every scan before waiting for any, so they run at the same time
+	for _, t := range targets {
+		started, err := registry.StartScan(t.name, t.reference)
+		if err != nil {
+			return err
+		}
+		if started {
+			fmt.Fprintf(os.Stderr, "Scanning %s\n", t.image())
+		} else {
+			fmt.Fprintf(os.Stderr, "%s was already scanned today, using those findings\n", t.image())
+		}
+	}
+	reports := make([]ecr.ScanReport, 0, len(targets))
+	for _, t := range targets {
+		scan, err := registry.WaitForScan(t.name, t.reference, x.Timeout)
+		if err != nil {
+			return err
+		}
+		ecr.SortVulnerabilities(scan.Vulnerabilities)
+		reports = append(reports, ecr.ScanReport{Image: t.image(), Apps: t.apps, Scan: scan})
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	switch x.Output {
+	case "json":
+		return encoder.Encode(reports)
+	case "sarif":
+		return encoder.Encode(ecr.NewSARIF(reports))
+	}
+	printScanTable(reports)
+	return nil
+}
+
+func init() {
+	parser.AddCommand("scan",
+		"Scan",
+		"Scan images and report their findings by severity",
+		&scanCommand)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/isotoma/k8ecr/pkg/apps"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+)
+
+// StatusCommand reports the versions running across namespaces
+type StatusCommand struct {
+	Contexts []string `long:"context" description:"Kubeconfig context to report on, may be repeated"`
+	Output   string   `short:"o" long:"output" choice:"table" choice:"json" choice:"markdown" default:"table" description:"Output format"`
+}
+
+var statusCommand StatusCommand
+
+func formatCell(cell *apps.StatusCell, emphasis string) string {
+	if cell == nil {
+		return "-"
+	}
+	text := strings.Join(cell.Versions, ", ")
+	if cell.Mixed {
+		text += " (mixed)"
+	}
+	if cell.Outdated {
+		text += " *"
+	}
+	if emphasis != "" && (cell.Mixed || cell.Outdated) {
+		text = emphasis + text + emphasis
+	}
+	return text
+}
+
+func formatLatest(row *apps.StatusRow) string {
+	if row.External {
+		return "(not in ECR)"
+	}
+	return row.Latest
+}
+
+func printStatusTable(status *apps.Status) {
+	table := uitable.New()
+	table.MaxColWidth = 60
+	cols := []interface{}{"APP", "IMAGE", "LATEST"}
+	for _, c := range status.Columns {
+		cols = append(cols, strings.ToUpper(c))
+	}
+	table.AddRow(cols...)
+	for _, row := range status.Rows {
+		cells := []interface{}{row.App, row.Image, formatLatest(row)}
+		for _, c := range status.Columns {
+			cells = append(cells, formatCell(row.Cells[c], ""))
+		}
+		table.AddRow(cells...)
+	}
+	fmt.Println(table)
+	fmt.Println("\n* older than latest   (mixed) more than one version running")
+}
+
+func printStatusMarkdown(status *apps.Status) {
+	header := append([]string{"App", "Image", "Latest"}, status.Columns...)
+	fmt.Printf("| %s |\n", strings.Join(header, " | "))
+	fmt.Printf("|%s\n", strings.Repeat(" --- |", len(header)))
+	for _, row := range status.Rows {
+		cells := []string{row.App, "`" + row.Image + "`", formatLatest(row)}
+		if row.External {
+			cells[2] = "**" + cells[2] + "**"
+		}
+		for _, c := range status.Columns {
+			cells = append(cells, formatCell(row.Cells[c], "**"))
+		}
+		fmt.Printf("| %s |\n", strings.Join(cells, " | "))
+	}
+}
+
+// Execute the status command
+func (x *StatusCommand) Execute(args []string) error {
+	processOptions()
+	if len(args) == 0 {
+		return errors.New("Usage: k8ecr status NAMESPACE...")
+	}
+	registry := ecr.NewRegistry()
+	if err := registry.FetchAll(); err != nil {
+		return err
+	}
+	contexts := x.Contexts
+	if len(contexts) == 0 {
+		contexts = []string{""}
+	}
+	mgrs := make([]*apps.AppManager, 0)
+	for _, context := range contexts {
+		for _, namespace := range args {
+			mgr, err := apps.NewAppManagerForContext(context, namespace)
+			if err != nil {
+				return err
+			}
+			filter(registry, mgr)
+			mgrs = append(mgrs, mgr)
+		}
+	}
+	status := apps.NewStatus(mgrs)
+	switch x.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	case "markdown":
+		printStatusMarkdown(status)
+	default:
+		printStatusTable(status)
+	}
+	return nil
+}
+
+func init() {
+	parser.AddCommand(
+		"status",
+		"Status",
+		"Show the versions running in each namespace against the latest in ECR",
+		&statusCommand)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/isotoma/k8ecr/pkg/apps"
+	"github.com/isotoma/k8ecr/pkg/ecr"
+	"github.com/isotoma/k8ecr/pkg/registry"
+)
+
+// SignatureOptions refuse deploys of images that are not signed with cosign
+type SignatureOptions struct {
+	VerifyKeys []string `long:"verify-key" env:"K8ECR_VERIFY_KEYS" env-delim:"," description:"Refuse to deploy images without a cosign signature made with the public key in this file, may be repeated"`
+}
+
+func (o *SignatureOptions) publicKeys() ([]crypto.PublicKey, error) {
+	keys := make([]crypto.PublicKey, 0)
+	for _, file := range o.VerifyKeys {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		k, err := registry.ParsePublicKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		keys = append(keys, k...)
+	}
+	return keys, nil
+}
+
+// checkSignatures blocks the changesets whose target image does not have a
+// valid signature made with one of the keys
+func (o *SignatureOptions) checkSignatures(mgrs []*apps.AppManager) error {
+	if len(o.VerifyKeys) == 0 {
+		return nil
+	}
+	keys, err := o.publicKeys()
+	if err != nil {
+		return err
+	}
+	clients := make(map[string]*registry.Client)
+	results := make(map[string]string)
+	check := func(app string, cs *apps.ChangeSet) (string, error) {
+		client, ok := clients[cs.ImageID.Registry]
+		if !ok {
+			if client, err = ecr.ClientFor(cs.ImageID.Registry, ""); err != nil {
+				return "", err
+			}
+			clients[cs.ImageID.Registry] = client
+		}
+		digest := string(cs.UpdateTo)
+		if !strings.HasPrefix(digest, "sha256:") {
+			if d, ok := cs.Digests[digest]; ok {
+				digest = d
+			} else {
+				m, err := client.GetManifest(cs.ImageID.Repo, digest)
+				if err != nil {
+					return "", err
+				}
+				digest = m.Digest
+			}
+		}
+		key := cs.ImageID.Registry + "/" + cs.ImageID.Repo + "@" + digest
+		if reason, ok := results[key]; ok {
+			return reason, nil
+		}
+		reason := ""
+		err := client.VerifySignature(cs.ImageID.Repo, digest, keys)
+		if serr, ok := err.(*registry.SignatureError); ok {
+			reason = serr.Error()
+		} else if err != nil {
+			return "", err
+		}
+		results[key] = reason
+		return reason, nil
+	}
+	for _, mgr := range mgrs {
+		if err := mgr.Block(check); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package apps
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Adoption moves the containers running one version of an image outside
+// ECR onto a mirror of it in ECR
+type Adoption struct {
+	App       string
+	Source    ImageIdentifier
+	Reference string     // The tag or digest to mirror
+	Pinned    bool       // The containers are pinned by digest
+	ChangeSet *ChangeSet // Upgrades the containers to the mirror
+}
+
+// PinMode returns how to write the mirror's reference into the containers.
+// Containers pinned by digest stay pinned whatever the pin mode.
+func (a Adoption) PinMode(pin PinMode) PinMode {
+	if a.Pinned && pin == PinNone {
+		return PinTagDigest
+	}
+	return pin
+}
+
+// Adoptions plans moving every image outside ECR into the registry, in the
+// repository named by repository. If names are given only those apps are
+// adopted. Each changeset needs the digest of the mirrored image recorded
+// before it is upgraded with a pin mode.
+func (mgr *AppManager) Adoptions(registry string, repository func(ImageIdentifier) string, names []string) ([]Adoption, error) {
+	if len(names) == 0 {
+		for name := range mgr.Apps {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	adoptions := make([]Adoption, 0)
+	for _, name := range names {
+		app, ok := mgr.Apps[name]
+		if !ok {
+			return nil, fmt.Errorf("App %s not found in %s", name, mgr.Namespace)
+		}
+		for id, external := range app.External {
+			target := ImageIdentifier{Registry: registry, Repo: repository(id)}
+			// Containers on the same tag may be pinned to different digests
+			byImage := make(map[[2]string]*Adoption)
+			for kind, containers := range external.Containers {
+				for _, c := range containers {
+					key := [2]string{string(c.Current), c.Digest}
+					a, ok := byImage[key]
+					if !ok {
+						cs := NewChangeSet(target)
+						cs.UpdateTo = c.Current
+						cs.NeedsUpdate = true
+						a = &Adoption{App: name, Source: id, Reference: string(c.Current), ChangeSet: cs}
+						if c.Digest != "" {
+							a.Reference, a.Pinned = c.Digest, true
+						}
+						byImage[key] = a
+					}
+					a.ChangeSet.AddContainer(kind, c)
+				}
+			}
+			for _, a := range byImage {
+				adoptions = append(adoptions, *a)
+			}
+		}
+	}
+	sort.Slice(adoptions, func(i, j int) bool {
+		a, b := adoptions[i], adoptions[j]
+		if a.App != b.App {
+			return a.App < b.App
+		}
+		if a.Source.Repo != b.Source.Repo {
+			return a.Source.Repo < b.Source.Repo
+		}
+		if a.ChangeSet.UpdateTo != b.ChangeSet.UpdateTo {
+			return a.ChangeSet.UpdateTo < b.ChangeSet.UpdateTo
+		}
+		return a.Reference < b.Reference
+	})
+	return adoptions, nil
+}
@@ -0,0 +1,60 @@
+package apps
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAdoptions(T *testing.T) {
+	redis := ImageIdentifier{Registry: "docker.io", Repo: "library/redis"}
+	mgr := newTestManager("prod", container1)
+	mgr.AddExternalContainer("Deployment", Container{ContainerID: ContainerIdentifier{"cache", "redis"}, ImageID: redis, App: "App1", Current: "7"})
+	mgr.AddExternalContainer("Deployment", Container{ContainerID: ContainerIdentifier{"queue", "redis"}, ImageID: redis, App: "App1", Current: "6", Digest: "sha256:aaa"})
+	mgr.AddExternalContainer("CronJob", Container{ContainerID: ContainerIdentifier{"backup", "redis"}, ImageID: redis, App: "App1", Current: "7"})
+	name := func(id ImageIdentifier) string {
+		return strings.TrimPrefix(id.Repo, "library/")
+	}
+
+	adoptions, err := mgr.Adoptions("ecr", name, nil)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(adoptions) != 2 {
+		T.Fatalf("Expected an adoption per version, got %v", adoptions)
+	}
+	six, seven := adoptions[0], adoptions[1]
+	if six.Reference != "sha256:aaa" || six.ChangeSet.UpdateTo != "6" || six.ChangeSet.ImageID != (ImageIdentifier{Registry: "ecr", Repo: "redis"}) {
+		T.Errorf("Adoption of 6 is wrong: %v", six)
+	}
+	if seven.Reference != "7" || len(seven.ChangeSet.Containers["Deployment"]) != 1 || len(seven.ChangeSet.Containers["CronJob"]) != 1 {
+		T.Errorf("Adoption of 7 is wrong: %v", seven)
+	}
+	if ref, _ := seven.ChangeSet.ImageRef(PinNone); ref != "ecr/redis:7" {
+		T.Errorf("Adopted image reference is wrong: %s", ref)
+	}
+
+	pinned := newTestManager("prod")
+	pinned.AddExternalContainer("Deployment", Container{ContainerID: ContainerIdentifier{"cache", "redis"}, ImageID: redis, App: "App1", Current: "7"})
+	pinned.AddExternalContainer("Deployment", Container{ContainerID: ContainerIdentifier{"queue", "redis"}, ImageID: redis, App: "App1", Current: "7", Digest: "sha256:aaa"})
+	adoptions, err = pinned.Adoptions("ecr", name, nil)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(adoptions) != 2 {
+		T.Fatalf("Expected pinned and unpinned containers to be adopted separately, got %v", adoptions)
+	}
+	tagged, digested := adoptions[0], adoptions[1]
+	if tagged.Reference != "7" || tagged.Pinned || tagged.ChangeSet.Containers["Deployment"][0].ContainerID.Resource != "cache" {
+		T.Errorf("Adoption of the tag is wrong: %v", tagged)
+	}
+	if digested.Reference != "sha256:aaa" || !digested.Pinned || digested.ChangeSet.Containers["Deployment"][0].ContainerID.Resource != "queue" {
+		T.Errorf("Adoption of the digest is wrong: %v", digested)
+	}
+	if tagged.PinMode(PinNone) != PinNone || digested.PinMode(PinNone) != PinTagDigest || digested.PinMode(PinDigest) != PinDigest {
+		T.Error("Containers pinned by digest should stay pinned")
+	}
+
+	if _, err := mgr.Adoptions("ecr", name, []string{"App2"}); err == nil {
+		T.Error("Adoptions should fail for a missing app")
+	}
+}
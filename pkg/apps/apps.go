@@ -1,6 +1,7 @@
 package apps
 
 import (
+	"github.com/isotoma/k8ecr/pkg/cluster"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -8,6 +9,7 @@ import (
 type App struct {
 	Name       string
 	ChangeSets map[ImageIdentifier]*ChangeSet
+	External   map[ImageIdentifier]*ChangeSet // Images outside ECR, which are never upgraded
 }
 
 // NewApp returns a new App
@@ -15,6 +17,7 @@ func NewApp(name string) *App {
 	return &App{
 		Name:       name,
 		ChangeSets: make(map[ImageIdentifier]*ChangeSet),
+		External:   make(map[ImageIdentifier]*ChangeSet),
 	}
 }
 
@@ -37,23 +40,42 @@ func (app *App) SetLatest(registry, repository, version string) {
 
 }
 
+// SetDigests records the tag digests on the changeset for the repository
+func (app *App) SetDigests(registry, repository string, digests map[string]string) {
+	id := ImageIdentifier{Registry: registry, Repo: repository}
+	cs, ok := app.ChangeSets[id]
+	if ok {
+		cs.SetDigests(digests)
+	}
+}
+
 // AppManager finds and updates Applications
 // and their deployments and cronjobs
 type AppManager struct {
 	ClientSet kubernetes.Interface
+	Context   string
 	Namespace string
 	Apps      map[string]*App
 	Managers  map[string]*ResourceManager
+	Pin       PinMode
+	History   []Container // Containers found by ScanHistory
+	Init      []Container // Init containers found by Scan
 }
 
-// NewAppManager creates a new Image manager
+// NewAppManager creates a new Image manager using the current kubeconfig context
 func NewAppManager(namespace string) (*AppManager, error) {
-	clientset, err := getClientSet()
+	return NewAppManagerForContext("", namespace)
+}
+
+// NewAppManagerForContext creates a new Image manager using the named kubeconfig context
+func NewAppManagerForContext(context, namespace string) (*AppManager, error) {
+	clientset, err := cluster.GetClientSet(context)
 	if err != nil {
 		return nil, err
 	}
 	a := &AppManager{
 		ClientSet: clientset,
+		Context:   context,
 		Namespace: namespace,
 		Apps:      make(map[string]*App),
 		Managers:  resourceManagers,
@@ -70,6 +92,41 @@ func (mgr *AppManager) SetLatest(registry, repository, version string) {
 	}
 }
 
+// SetDigests calls SetDigests on all contained apps
+func (mgr *AppManager) SetDigests(registry, repository string, digests map[string]string) {
+	for _, app := range mgr.Apps {
+		app.SetDigests(registry, repository, digests)
+	}
+}
+
+// Gate returns why an app must not deploy the version a changeset would
+// upgrade to, or "" if it may
+type Gate func(app string, cs *ChangeSet) (string, error)
+
+// Block asks the gate about every changeset that needs update, and blocks
+// those it refuses. Reasons from several gates are kept together.
+func (mgr *AppManager) Block(gate Gate) error {
+	for _, app := range mgr.Apps {
+		for _, cs := range app.ChangeSets {
+			if !cs.NeedsUpdate {
+				continue
+			}
+			reason, err := gate(app.Name, cs)
+			if err != nil {
+				return err
+			}
+			if reason == "" {
+				continue
+			}
+			if cs.Blocked != "" {
+				cs.Blocked += "; "
+			}
+			cs.Blocked += reason
+		}
+	}
+	return nil
+}
+
 // AddContainer adds the specified container, from a resource of the specified kind
 // To the appropriate app
 func (mgr *AppManager) AddContainer(kind string, container Container) {
@@ -85,18 +142,77 @@ func (mgr *AppManager) AddContainer(kind string, container Container) {
 	changeset.AddContainer(kind, container)
 }
 
+// AddExternalContainer adds the specified container, whose image is not held
+// in ECR, to the appropriate app
+func (mgr *AppManager) AddExternalContainer(kind string, container Container) {
+	_, ok := mgr.Apps[container.App]
+	if !ok {
+		mgr.Apps[container.App] = NewApp(container.App)
+	}
+	_, ok = mgr.Apps[container.App].External[container.ImageID]
+	if !ok {
+		mgr.Apps[container.App].External[container.ImageID] = NewChangeSet(container.ImageID)
+	}
+	changeset := mgr.Apps[container.App].External[container.ImageID]
+	changeset.AddContainer(kind, container)
+}
+
 // Scan the cluster and find all resources and containers we manage
 func (mgr *AppManager) Scan() error {
 	for _, rm := range resourceManagers {
+		if rm.History {
+			continue
+		}
 		items, err := rm.Resources(mgr)
 		if err != nil {
 			return err
 		}
 		for _, item := range items {
 			for _, c := range rm.Generator(item) {
-				mgr.AddContainer(rm.Kind, c)
+				if c.Init {
+					mgr.Init = append(mgr.Init, c)
+				} else if c.ImageID.IsECR() {
+					mgr.AddContainer(rm.Kind, c)
+				} else {
+					mgr.AddExternalContainer(rm.Kind, c)
+				}
 			}
 		}
 	}
 	return nil
 }
+
+// ScanHistory finds the containers in superseded resources, which may still
+// be rolled back to
+func (mgr *AppManager) ScanHistory() error {
+	for _, rm := range resourceManagers {
+		if !rm.History {
+			continue
+		}
+		items, err := rm.Resources(mgr)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			mgr.History = append(mgr.History, rm.Generator(item)...)
+		}
+	}
+	return nil
+}
+
+// References returns every container found, whether or not it is managed,
+// including init containers
+func (mgr *AppManager) References() []Container {
+	refs := make([]Container, 0)
+	for _, app := range mgr.Apps {
+		for _, changesets := range []map[ImageIdentifier]*ChangeSet{app.ChangeSets, app.External} {
+			for _, cs := range changesets {
+				for _, containers := range cs.Containers {
+					refs = append(refs, containers...)
+				}
+			}
+		}
+	}
+	refs = append(refs, mgr.Init...)
+	return append(refs, mgr.History...)
+}
@@ -1,6 +1,7 @@
 package apps
 
 import (
+	"strings"
 	"testing"
 
 	"k8s.io/client-go/kubernetes/fake"
@@ -29,3 +30,41 @@ func TestAddContainer(T *testing.T) {
 		T.Errorf("AddContainer failed")
 	}
 }
+
+func TestReferences(T *testing.T) {
+	mgr := newTestManager("default", container1)
+	mgr.AddExternalContainer("Foo", Container{ImageID: ImageIdentifier{Registry: "docker.io", Repo: "library/redis"}, App: "App1"})
+	mgr.History = append(mgr.History, container2)
+	mgr.Init = append(mgr.Init, container2)
+	if refs := mgr.References(); len(refs) != 4 {
+		T.Errorf("References should include managed, external, init and history containers: %v", refs)
+	}
+}
+
+func TestBlock(T *testing.T) {
+	mgr := newTestManager("default", container1)
+	mgr.SetLatest("reg1", "repo1", "1.0.0")
+	calls := 0
+	err := mgr.Block(func(app string, cs *ChangeSet) (string, error) {
+		calls++
+		if app != "App1" || cs.UpdateTo != "1.0.0" {
+			T.Errorf("Block asked about the wrong changeset: %s %s", app, cs.UpdateTo)
+		}
+		return "2 CRITICAL", nil
+	})
+	if err != nil || calls != 1 {
+		T.Fatalf("Block failed: %v, %d calls", err, calls)
+	}
+	cs := mgr.Apps["App1"].ChangeSets[id1]
+	if cs.Blocked != "2 CRITICAL" {
+		T.Errorf("Block did not record the reason: %q", cs.Blocked)
+	}
+	if err := cs.Upgrade(mgr); err == nil || !strings.Contains(err.Error(), "2 CRITICAL") {
+		T.Errorf("Upgrade should refuse a blocked changeset: %v", err)
+	}
+	mgr.Block(func(app string, cs *ChangeSet) (string, error) { return "", nil })
+	mgr.Block(func(app string, cs *ChangeSet) (string, error) { return "not signed", nil })
+	if cs.Blocked != "2 CRITICAL; not signed" {
+		T.Errorf("Block should keep the reasons of every gate: %q", cs.Blocked)
+	}
+}
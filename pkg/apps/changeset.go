@@ -3,6 +3,7 @@ package apps
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/Masterminds/semver"
 )
@@ -10,12 +11,29 @@ import (
 // Version is a version number expressed as a string
 type Version string
 
+// PinMode controls how image references are written when upgrading
+type PinMode int
+
+const (
+	// PinNone writes registry/repo:tag
+	PinNone PinMode = iota
+	// PinTagDigest writes registry/repo:tag@sha256:...
+	PinTagDigest
+	// PinDigest writes registry/repo@sha256:...
+	PinDigest
+)
+
 // ImageIdentifier images are identified by their repo and registry
 type ImageIdentifier struct {
 	Repo     string
 	Registry string
 }
 
+// IsECR reports whether the image is held in an ECR registry
+func (id ImageIdentifier) IsECR() bool {
+	return strings.HasSuffix(id.Registry, "amazonaws.com")
+}
+
 // ContainerIdentifier is a unique identifier for a container
 type ContainerIdentifier struct {
 	Resource  string
@@ -28,6 +46,9 @@ type Container struct {
 	ImageID     ImageIdentifier
 	App         string
 	Current     Version
+	Digest      string // Set if the image reference is pinned by digest
+	Placement   Placement
+	Init        bool // Set for init containers, which are referenced but never upgraded
 }
 
 // ChangeSet contains resources that share an image identifier
@@ -36,6 +57,8 @@ type ChangeSet struct {
 	NeedsUpdate bool
 	UpdateTo    Version
 	Containers  map[string][]Container // Map of Kinds to lists of containers
+	Digests     map[string]string      // Map of tags to image digests
+	Blocked     string                 // Why UpdateTo must not be deployed, if it must not
 }
 
 // NewChangeSet creates a new changeset
@@ -45,6 +68,7 @@ func NewChangeSet(ID ImageIdentifier) *ChangeSet {
 		NeedsUpdate: false,
 		UpdateTo:    "",
 		Containers:  make(map[string][]Container),
+		Digests:     make(map[string]string),
 	}
 }
 
@@ -77,6 +101,58 @@ func (cs *ChangeSet) SetLatest(version string) {
 	}
 }
 
+// SetVersion sets the version to deploy regardless of ordering, and marks
+// the changeset as needing update if any container runs something else
+func (cs *ChangeSet) SetVersion(version string) {
+	cs.UpdateTo = Version(version)
+	cs.NeedsUpdate = false
+	for _, v := range cs.Versions() {
+		if v != version {
+			cs.NeedsUpdate = true
+		}
+	}
+}
+
+// SetDigests records the digest of each tag in the repository. Containers
+// that are pinned only by digest have their current version replaced by
+// the tag that digest is published under, so they can be compared.
+func (cs *ChangeSet) SetDigests(digests map[string]string) {
+	tags := make(map[string][]string)
+	for tag, digest := range digests {
+		cs.Digests[tag] = digest
+		tags[digest] = append(tags[digest], tag)
+	}
+	for _, containers := range cs.Containers {
+		for i, c := range containers {
+			if c.Digest == "" || c.Current != Version(c.Digest) {
+				continue
+			}
+			if tag := preferredTag(tags[c.Digest]); tag != "" {
+				containers[i].Current = Version(tag)
+			}
+		}
+	}
+}
+
+// preferredTag chooses which of several tags on one image to report,
+// preferring the highest semantic version and avoiding "latest"
+func preferredTag(tags []string) string {
+	sort.Strings(tags)
+	best := ""
+	var bestVersion *semver.Version
+	for _, t := range tags {
+		if v, err := semver.NewVersion(t); err == nil {
+			if bestVersion == nil || v.GreaterThan(bestVersion) {
+				best, bestVersion = t, v
+			}
+		} else if bestVersion == nil && (best == "" || best == "latest") {
+			best = t
+		}
+	}
+	return best
+}
+
+// AddContainer adds a container from a resource of the specified kind
 func (cs *ChangeSet) AddContainer(kind string, container Container) {
 	_, ok := cs.Containers[kind]
 	if !ok {
@@ -87,6 +163,9 @@ func (cs *ChangeSet) AddContainer(kind string, container Container) {
 
 // Upgrade all of the resources in this changeset, using the managers in the appmanager
 func (cs *ChangeSet) Upgrade(mgr *AppManager) error {
+	if cs.Blocked != "" {
+		return fmt.Errorf("Not deploying %s: %s", cs.RegistryPath(), cs.Blocked)
+	}
 	fmt.Printf("Updating image %s:\n", cs.ImageID.Repo)
 	for kind, resources := range cs.Containers {
 		for _, resource := range resources {
@@ -118,5 +197,25 @@ func (cs *ChangeSet) Versions() []string {
 
 // RegistryPath returns the full registry path to which all images in the changeset should use
 func (cs *ChangeSet) RegistryPath() string {
+	if strings.HasPrefix(string(cs.UpdateTo), "sha256:") {
+		// Images that have no tag can only be referred to by digest
+		return fmt.Sprintf("%s/%s@%s", cs.ImageID.Registry, cs.ImageID.Repo, cs.UpdateTo)
+	}
 	return fmt.Sprintf("%s/%s:%s", cs.ImageID.Registry, cs.ImageID.Repo, cs.UpdateTo)
 }
+
+// ImageRef returns the image reference to write into containers, pinned
+// by digest according to the pin mode
+func (cs *ChangeSet) ImageRef(pin PinMode) (string, error) {
+	if pin == PinNone || strings.HasPrefix(string(cs.UpdateTo), "sha256:") {
+		return cs.RegistryPath(), nil
+	}
+	digest, ok := cs.Digests[string(cs.UpdateTo)]
+	if !ok {
+		return "", fmt.Errorf("No digest known for %s", cs.RegistryPath())
+	}
+	if pin == PinDigest {
+		return fmt.Sprintf("%s/%s@%s", cs.ImageID.Registry, cs.ImageID.Repo, digest), nil
+	}
+	return fmt.Sprintf("%s@%s", cs.RegistryPath(), digest), nil
+}
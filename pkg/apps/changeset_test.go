@@ -85,3 +85,43 @@ func TestVersions(T *testing.T) {
 		T.Errorf("Versions is wrong: %v", versions)
 	}
 }
+
+func TestSetDigests(T *testing.T) {
+	cs := NewChangeSet(id1)
+	pinned := container1
+	pinned.Current = "sha256:bbb"
+	pinned.Digest = "sha256:bbb"
+	cs.AddContainer("Foo", pinned)
+	cs.SetDigests(map[string]string{"latest": "sha256:bbb", "1.0.0": "sha256:bbb", "0.9.0": "sha256:aaa"})
+	if cs.Containers["Foo"][0].Current != "1.0.0" {
+		T.Errorf("Digest pinned container should report its tag, got %s", cs.Containers["Foo"][0].Current)
+	}
+	cs.SetLatest("1.0.0")
+	if cs.NeedsUpdate {
+		T.Errorf("Digest pinned container at latest version should not need update")
+	}
+}
+
+func TestImageRef(T *testing.T) {
+	cs := NewChangeSet(id1)
+	cs.SetDigests(map[string]string{"1.0.0": "sha256:bbb"})
+	cs.SetLatest("1.0.0")
+	for pin, expected := range map[PinMode]string{
+		PinNone:      "reg1/repo1:1.0.0",
+		PinTagDigest: "reg1/repo1:1.0.0@sha256:bbb",
+		PinDigest:    "reg1/repo1@sha256:bbb",
+	} {
+		ref, err := cs.ImageRef(pin)
+		if err != nil || ref != expected {
+			T.Errorf("ImageRef(%d) is wrong: %s %v", pin, ref, err)
+		}
+	}
+	cs.SetLatest("1.0.1")
+	if _, err := cs.ImageRef(PinDigest); err == nil {
+		T.Errorf("ImageRef should fail without a known digest")
+	}
+	cs.SetVersion("sha256:ccc")
+	if ref, _ := cs.ImageRef(PinNone); ref != "reg1/repo1@sha256:ccc" {
+		T.Errorf("ImageRef should refer to untagged images by digest: %s", ref)
+	}
+}
@@ -1,7 +1,11 @@
 package apps
 
+// ResourceManager finds, and upgrades, the containers in one kind of resource.
+// History managers find superseded resources, such as old ReplicaSets, whose
+// containers are recorded but never upgraded.
 type ResourceManager struct {
 	Kind      string
+	History   bool
 	Resources func(mgr *AppManager) ([]interface{}, error)
 	Generator func(item interface{}) []Container
 	Upgrade   func(mgr *AppManager, image *ChangeSet, resource Container) error
@@ -0,0 +1,153 @@
+package apps
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Placement is where a container's pod can be scheduled
+type Placement struct {
+	NodeSelector map[string]string
+	Required     *corev1.NodeSelector // Required node affinity
+}
+
+// Node is a node of the cluster
+type Node struct {
+	Name   string
+	Labels map[string]string
+}
+
+// Platform returns the os/arch of the node, or "" if its labels do not say
+func (n Node) Platform() string {
+	label := func(name string) string {
+		if v, ok := n.Labels["kubernetes.io/"+name]; ok {
+			return v
+		}
+		return n.Labels["beta.kubernetes.io/"+name]
+	}
+	os, arch := label("os"), label("arch")
+	if os == "" || arch == "" {
+		return ""
+	}
+	return os + "/" + arch
+}
+
+// Nodes lists the nodes of the cluster
+func (mgr *AppManager) Nodes() ([]Node, error) {
+	response, err := mgr.ClientSet.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]Node, len(response.Items))
+	for i, n := range response.Items {
+		nodes[i] = Node{Name: n.Name, Labels: n.Labels}
+	}
+	return nodes, nil
+}
+
+func matchExpression(e corev1.NodeSelectorRequirement, value string, ok bool) bool {
+	switch e.Operator {
+	case corev1.NodeSelectorOpIn, corev1.NodeSelectorOpNotIn:
+		found := false
+		for _, v := range e.Values {
+			if ok && v == value {
+				found = true
+			}
+		}
+		return found == (e.Operator == corev1.NodeSelectorOpIn)
+	case corev1.NodeSelectorOpExists:
+		return ok
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !ok
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !ok || len(e.Values) != 1 {
+			return false
+		}
+		have, err1 := strconv.ParseInt(value, 10, 64)
+		want, err2 := strconv.ParseInt(e.Values[0], 10, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		return (e.Operator == corev1.NodeSelectorOpGt && have > want) ||
+			(e.Operator == corev1.NodeSelectorOpLt && have < want)
+	}
+	return false
+}
+
+// matchTerm reports whether the node meets every requirement of the term.
+// A term without requirements matches no nodes.
+func matchTerm(term corev1.NodeSelectorTerm, node Node) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	for _, e := range term.MatchExpressions {
+		value, ok := node.Labels[e.Key]
+		if !matchExpression(e, value, ok) {
+			return false
+		}
+	}
+	for _, e := range term.MatchFields {
+		if e.Key != "metadata.name" || !matchExpression(e, node.Name, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether the pod can be scheduled on the node, by its node
+// selector and any one of its required node affinity terms
+func (p Placement) Matches(node Node) bool {
+	for k, v := range p.NodeSelector {
+		if node.Labels[k] != v {
+			return false
+		}
+	}
+	if p.Required == nil || len(p.Required.NodeSelectorTerms) == 0 {
+		return true
+	}
+	for _, term := range p.Required.NodeSelectorTerms {
+		if matchTerm(term, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// PlatformMismatches describes each container of the changeset that can be
+// scheduled on nodes whose platform the image, with the os/arch platforms,
+// does not support
+func (cs *ChangeSet) PlatformMismatches(nodes []Node, platforms []string) []string {
+	supported := make(map[string]bool)
+	for _, p := range platforms {
+		supported[p] = true
+	}
+	problems := make([]string, 0)
+	for kind, containers := range cs.Containers {
+		for _, c := range containers {
+			unsupported := make(map[string]bool)
+			for _, node := range nodes {
+				platform := node.Platform()
+				if platform != "" && !supported[platform] && c.Placement.Matches(node) {
+					unsupported[platform] = true
+				}
+			}
+			if len(unsupported) == 0 {
+				continue
+			}
+			list := make([]string, 0, len(unsupported))
+			for p := range unsupported {
+				list = append(list, p)
+			}
+			sort.Strings(list)
+			problems = append(problems, fmt.Sprintf("%s %s/%s can run on %s nodes",
+				kind, c.ContainerID.Resource, c.ContainerID.Container, strings.Join(list, ", ")))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,80 @@
+package apps
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	amd64Node = Node{Name: "a", Labels: map[string]string{"kubernetes.io/os": "linux", "kubernetes.io/arch": "amd64", "pool": "general"}}
+	arm64Node = Node{Name: "b", Labels: map[string]string{"beta.kubernetes.io/os": "linux", "beta.kubernetes.io/arch": "arm64", "pool": "graviton"}}
+)
+
+func TestPlacementMatches(T *testing.T) {
+	arm := &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+		MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "kubernetes.io/arch", Operator: corev1.NodeSelectorOpIn, Values: []string{"arm64"}}},
+	}, {
+		MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "pool", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"general"}}},
+	}}}
+	tests := []struct {
+		placement Placement
+		node      Node
+		expected  bool
+	}{
+		{Placement{}, amd64Node, true},
+		{Placement{NodeSelector: map[string]string{"pool": "graviton"}}, amd64Node, false},
+		{Placement{NodeSelector: map[string]string{"pool": "graviton"}}, arm64Node, true},
+		{Placement{Required: arm}, amd64Node, false},
+		{Placement{Required: arm}, arm64Node, true},
+		{Placement{Required: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{}}}}, amd64Node, false},
+		{Placement{Required: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+			MatchFields: []corev1.NodeSelectorRequirement{{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}}},
+		}}}}, amd64Node, true},
+	}
+	for i, t := range tests {
+		if t.placement.Matches(t.node) != t.expected {
+			T.Errorf("Test %d: expected Matches(%s) to be %v", i, t.node.Name, t.expected)
+		}
+	}
+}
+
+func TestPlatformMismatches(T *testing.T) {
+	anywhere := container1
+	onArm := container2
+	onArm.Placement = Placement{NodeSelector: map[string]string{"pool": "graviton"}}
+	cs := NewChangeSet(id1)
+	cs.AddContainer("Deployment", anywhere)
+	cs.AddContainer("CronJob", onArm)
+	nodes := []Node{amd64Node, arm64Node, {Name: "unlabelled"}}
+
+	if problems := cs.PlatformMismatches(nodes, []string{"linux/amd64", "linux/arm64"}); len(problems) != 0 {
+		T.Errorf("Expected a multi-platform image to fit, got %v", problems)
+	}
+	expected := []string{
+		"CronJob Resource2/app can run on linux/arm64 nodes",
+		"Deployment Resource1/app can run on linux/arm64 nodes",
+	}
+	if problems := cs.PlatformMismatches(nodes, []string{"linux/amd64"}); !reflect.DeepEqual(problems, expected) {
+		T.Errorf("Unexpected problems for an amd64 image: %v", problems)
+	}
+	expected = []string{"Deployment Resource1/app can run on linux/amd64 nodes"}
+	if problems := cs.PlatformMismatches(nodes, []string{"linux/arm64"}); !reflect.DeepEqual(problems, expected) {
+		T.Errorf("Unexpected problems for an arm64 image: %v", problems)
+	}
+}
+
+func TestNodes(T *testing.T) {
+	mgr := newTestManager("default")
+	mgr.ClientSet = fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: amd64Node.Labels}})
+	nodes, err := mgr.Nodes()
+	if err != nil || len(nodes) != 1 || nodes[0].Platform() != "linux/amd64" {
+		T.Errorf("Unexpected nodes %v %v", nodes, err)
+	}
+	if platform := arm64Node.Platform(); platform != "linux/arm64" {
+		T.Errorf("Expected the beta labels to be read, got %q", platform)
+	}
+}
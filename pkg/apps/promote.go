@@ -0,0 +1,79 @@
+package apps
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Promotion is a changeset that will be set to the version running in
+// another namespace
+type Promotion struct {
+	App       string
+	ChangeSet *ChangeSet
+}
+
+// PromoteFrom sets every changeset to the version the matching changeset in
+// the source runs, and returns those that need update. If names are given
+// only those apps are promoted, and each must run in both namespaces.
+func (mgr *AppManager) PromoteFrom(source *AppManager, names []string) ([]Promotion, error) {
+	explicit := len(names) > 0
+	if !explicit {
+		for name := range source.Apps {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	promotions := make([]Promotion, 0)
+	for _, name := range names {
+		sourceApp, ok := source.Apps[name]
+		if !ok {
+			return nil, fmt.Errorf("App %s not found in %s", name, source.Namespace)
+		}
+		targetApp, ok := mgr.Apps[name]
+		if !ok {
+			if explicit {
+				return nil, fmt.Errorf("App %s not found in %s", name, mgr.Namespace)
+			}
+			continue
+		}
+		for _, sourceCs := range sourceApp.GetChangeSets() {
+			cs, ok := targetApp.ChangeSets[sourceCs.ImageID]
+			if !ok {
+				continue
+			}
+			versions := sourceCs.Versions()
+			if len(versions) != 1 {
+				return nil, fmt.Errorf("App %s runs several versions of %s in %s: %v", name, sourceCs.ImageID.Repo, source.Namespace, versions)
+			}
+			if digest := sourceCs.digest(); digest != "" {
+				cs.Digests[versions[0]] = digest
+			}
+			cs.SetVersion(versions[0])
+			if cs.NeedsUpdate {
+				promotions = append(promotions, Promotion{App: name, ChangeSet: cs})
+			}
+		}
+	}
+	sort.Slice(promotions, func(i, j int) bool {
+		if promotions[i].App != promotions[j].App {
+			return promotions[i].App < promotions[j].App
+		}
+		return promotions[i].ChangeSet.ImageID.Repo < promotions[j].ChangeSet.ImageID.Repo
+	})
+	return promotions, nil
+}
+
+// digest returns the digest every container in the changeset is pinned to,
+// or "" if they are not all pinned to the same one
+func (cs *ChangeSet) digest() string {
+	digest := ""
+	for _, containers := range cs.Containers {
+		for _, c := range containers {
+			if c.Digest == "" || (digest != "" && c.Digest != digest) {
+				return ""
+			}
+			digest = c.Digest
+		}
+	}
+	return digest
+}
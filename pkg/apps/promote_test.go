@@ -0,0 +1,59 @@
+package apps
+
+import (
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestManager(namespace string, containers ...Container) *AppManager {
+	mgr := &AppManager{
+		ClientSet: fake.NewSimpleClientset(),
+		Namespace: namespace,
+		Apps:      make(map[string]*App),
+		Managers:  make(map[string]*ResourceManager),
+	}
+	for _, c := range containers {
+		mgr.AddContainer("Foo", c)
+	}
+	return mgr
+}
+
+func TestPromoteFrom(T *testing.T) {
+	staging := newTestManager("staging", container2)
+	prod := newTestManager("prod", container1)
+	promotions, err := prod.PromoteFrom(staging, nil)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(promotions) != 1 || promotions[0].App != "App1" || promotions[0].ChangeSet.UpdateTo != "1.0.0" {
+		T.Errorf("PromoteFrom returned wrong promotions: %v", promotions)
+	}
+	downgrade := newTestManager("prod", container2)
+	promotions, _ = downgrade.PromoteFrom(newTestManager("staging", container1), nil)
+	if len(promotions) != 1 || promotions[0].ChangeSet.UpdateTo != "0.1.0" {
+		T.Errorf("PromoteFrom should promote older versions too")
+	}
+	if _, err := prod.PromoteFrom(newTestManager("staging", container1, container2), nil); err == nil {
+		T.Errorf("PromoteFrom should refuse a source running mixed versions")
+	}
+	if _, err := prod.PromoteFrom(staging, []string{"App2"}); err == nil {
+		T.Errorf("PromoteFrom should refuse apps missing from the source")
+	}
+	other := container2
+	other.App = "App2"
+	source := newTestManager("staging", container2, other)
+	if _, err := prod.PromoteFrom(source, []string{"App2"}); err == nil {
+		T.Errorf("PromoteFrom should refuse apps named but missing from the target")
+	}
+	if promotions, err := prod.PromoteFrom(source, nil); err != nil || len(promotions) != 1 {
+		T.Errorf("PromoteFrom should skip apps missing from the target when promoting all: %v %v", promotions, err)
+	}
+	untagged := container2
+	untagged.Current = "sha256:ccc"
+	untagged.Digest = "sha256:ccc"
+	promotions, _ = prod.PromoteFrom(newTestManager("staging", untagged), nil)
+	if len(promotions) != 1 || promotions[0].ChangeSet.RegistryPath() != "reg1/repo1@sha256:ccc" {
+		T.Errorf("PromoteFrom should refer to untagged versions by digest")
+	}
+}
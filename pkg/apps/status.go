@@ -0,0 +1,94 @@
+package apps
+
+import (
+	"fmt"
+	"sort"
+)
+
+// StatusCell is the versions of an image running in one namespace
+type StatusCell struct {
+	Versions []string `json:"versions"`
+	Mixed    bool     `json:"mixed"`
+	Outdated bool     `json:"outdated"`
+}
+
+// StatusRow is an image used by an app, and the versions running in each
+// namespace, keyed by column
+type StatusRow struct {
+	App      string                 `json:"app"`
+	Image    string                 `json:"image"`
+	Latest   string                 `json:"latest,omitempty"`
+	External bool                   `json:"external"`
+	Cells    map[string]*StatusCell `json:"namespaces"`
+}
+
+// Status is a matrix of apps and their images against namespaces
+type Status struct {
+	Columns []string     `json:"columns"`
+	Rows    []*StatusRow `json:"rows"`
+}
+
+// Column names the namespace this manager scans, qualified by its
+// kubeconfig context if it has one
+func (mgr *AppManager) Column() string {
+	if mgr.Context == "" {
+		return mgr.Namespace
+	}
+	return fmt.Sprintf("%s/%s", mgr.Context, mgr.Namespace)
+}
+
+// NewStatus builds the status matrix from managers that have been scanned
+// and had their latest versions set
+func NewStatus(mgrs []*AppManager) *Status {
+	status := &Status{
+		Columns: make([]string, 0),
+		Rows:    make([]*StatusRow, 0),
+	}
+	rows := make(map[string]*StatusRow)
+	add := func(column string, app *App, cs *ChangeSet, external bool) {
+		image := cs.ImageID.Repo
+		if external {
+			image = fmt.Sprintf("%s/%s", cs.ImageID.Registry, cs.ImageID.Repo)
+		}
+		key := app.Name + " " + image
+		row, ok := rows[key]
+		if !ok {
+			row = &StatusRow{
+				App:      app.Name,
+				Image:    image,
+				External: external,
+				Cells:    make(map[string]*StatusCell),
+			}
+			rows[key] = row
+			status.Rows = append(status.Rows, row)
+		}
+		if cs.UpdateTo != "" {
+			row.Latest = string(cs.UpdateTo)
+		}
+		versions := cs.Versions()
+		row.Cells[column] = &StatusCell{
+			Versions: versions,
+			Mixed:    len(versions) > 1,
+			Outdated: cs.NeedsUpdate,
+		}
+	}
+	for _, mgr := range mgrs {
+		column := mgr.Column()
+		status.Columns = append(status.Columns, column)
+		for _, app := range mgr.Apps {
+			for _, cs := range app.ChangeSets {
+				add(column, app, cs, false)
+			}
+			for _, cs := range app.External {
+				add(column, app, cs, true)
+			}
+		}
+	}
+	sort.Slice(status.Rows, func(i, j int) bool {
+		if status.Rows[i].App != status.Rows[j].App {
+			return status.Rows[i].App < status.Rows[j].App
+		}
+		return status.Rows[i].Image < status.Rows[j].Image
+	})
+	return status
+}
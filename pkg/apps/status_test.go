@@ -0,0 +1,36 @@
+package apps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewStatus(T *testing.T) {
+	external := ImageIdentifier{Registry: "docker.io", Repo: "library/redis"}
+	staging := newTestManager("staging", container1, container2)
+	staging.AddExternalContainer("Foo", Container{ImageID: external, App: "App1", Current: "5"})
+	prod := newTestManager("prod", container2)
+	prod.Context = "cluster1"
+	staging.SetLatest("reg1", "repo1", "1.0.0")
+	prod.SetLatest("reg1", "repo1", "1.0.0")
+	status := NewStatus([]*AppManager{staging, prod})
+	if !reflect.DeepEqual(status.Columns, []string{"staging", "cluster1/prod"}) {
+		T.Errorf("Columns are wrong: %v", status.Columns)
+	}
+	if len(status.Rows) != 2 {
+		T.Fatalf("Expected a row per image, got %d", len(status.Rows))
+	}
+	row := status.Rows[1]
+	if row.Image != "repo1" || row.Latest != "1.0.0" || row.External {
+		T.Errorf("ECR row is wrong: %v", row)
+	}
+	if cell := row.Cells["staging"]; !cell.Mixed || !cell.Outdated {
+		T.Errorf("Staging should be mixed and outdated: %v", cell)
+	}
+	if cell := row.Cells["cluster1/prod"]; cell.Mixed || cell.Outdated {
+		T.Errorf("Prod should be current: %v", cell)
+	}
+	if row := status.Rows[0]; !row.External || row.Image != "docker.io/library/redis" || row.Cells["cluster1/prod"] != nil {
+		T.Errorf("External row is wrong: %v", row)
+	}
+}
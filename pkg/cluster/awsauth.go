@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"gopkg.in/yaml.v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// roleMapping is an entry in the mapRoles of the aws-auth ConfigMap
+type roleMapping struct {
+	RoleARN  string   `yaml:"rolearn"`
+	Username string   `yaml:"username"`
+	Groups   []string `yaml:"groups"`
+}
+
+// nodeRoles returns the roles mapped to the system:nodes group
+func nodeRoles(mapRoles string) ([]string, error) {
+	mappings := make([]roleMapping, 0)
+	if err := yaml.Unmarshal([]byte(mapRoles), &mappings); err != nil {
+		return nil, err
+	}
+	roles := make([]string, 0)
+	for _, m := range mappings {
+		for _, g := range m.Groups {
+			if g == "system:nodes" {
+				roles = append(roles, m.RoleARN)
+				break
+			}
+		}
+	}
+	return roles, nil
+}
+
+// NodeRoles returns the IAM roles the aws-auth ConfigMap of an EKS cluster
+// maps to nodes, for the named kubeconfig context. It returns nil if there
+// is no aws-auth ConfigMap, as the cluster is not EKS.
+func NodeRoles(context string) ([]string, error) {
+	clientset, err := GetClientSet(context)
+	if err != nil {
+		return nil, err
+	}
+	cm, err := clientset.CoreV1().ConfigMaps("kube-system").Get("aws-auth", metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nodeRoles(cm.Data["mapRoles"])
+}
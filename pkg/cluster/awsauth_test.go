@@ -0,0 +1,32 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+const mapRoles = `
+- rolearn: arn:aws:iam::123456789012:role/eks-nodes
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+    - system:bootstrappers
+    - system:nodes
+- rolearn: arn:aws:iam::123456789012:role/admins
+  username: admin
+  groups:
+    - system:masters
+`
+
+func TestNodeRoles(T *testing.T) {
+	roles, err := nodeRoles(mapRoles)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if !reflect.DeepEqual(roles, []string{"arn:aws:iam::123456789012:role/eks-nodes"}) {
+		T.Errorf("nodeRoles is wrong: %v", roles)
+	}
+	roles, err = nodeRoles("")
+	if err != nil || len(roles) != 0 {
+		T.Errorf("nodeRoles of empty mapRoles is wrong: %v %v", roles, err)
+	}
+}
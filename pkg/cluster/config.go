@@ -0,0 +1,99 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func homeDir() string {
+	if h := os.Getenv("HOME"); h != "" {
+		return h
+	}
+	return os.Getenv("USERPROFILE") // windows
+}
+
+// InCluster reports whether no kubeconfig file can be found, in which case
+// we assume we are running in-cluster
+func InCluster() bool {
+	if os.Getenv("KUBECONFIG") != "" {
+		return false
+	}
+	defpath := filepath.Join(homeDir(), ".kube", "config")
+	_, err := os.Stat(defpath)
+	return err != nil
+}
+
+func clientConfig(context string) clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+}
+
+// GetConfig returns the configuration for the named kubeconfig context,
+// or for the current context if none is named
+func GetConfig(context string) (*rest.Config, error) {
+	if InCluster() {
+		if context != "" {
+			return nil, errors.New("No kubeconfig found for context " + context)
+		}
+		return rest.InClusterConfig()
+	}
+	return clientConfig(context).ClientConfig()
+}
+
+// GetClientSet returns a clientset for the named kubeconfig context,
+// or for the current context if none is named
+func GetClientSet(context string) (*kubernetes.Clientset, error) {
+	config, err := GetConfig(context)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// Contexts returns the names of all contexts in the kubeconfig, sorted
+func Contexts() ([]string, error) {
+	if InCluster() {
+		return nil, errors.New("No kubeconfig found")
+	}
+	config, err := clientConfig("").RawConfig()
+	if err != nil {
+		return nil, err
+	}
+	contexts := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
+// Name returns the name of the cluster for the named kubeconfig context, or
+// the current context if none is named. Clusters created by kops use the
+// cluster name as the context name, and so do we.
+func Name(context string) (string, error) {
+	if InCluster() {
+		return "", errors.New("Cannot determine the cluster name when running in-cluster, please specify it")
+	}
+	config, err := clientConfig("").RawConfig()
+	if err != nil {
+		return "", err
+	}
+	if context == "" {
+		context = config.CurrentContext
+	}
+	if context == "" {
+		return "", errors.New("No current context is set in the kubeconfig")
+	}
+	if _, ok := config.Contexts[context]; !ok {
+		return "", fmt.Errorf("Context %s not found in the kubeconfig", context)
+	}
+	return context, nil
+}
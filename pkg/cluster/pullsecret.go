@@ -0,0 +1,130 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Pull secret labels and annotations
+const (
+	ManagedByLabel    = "app.kubernetes.io/managed-by"
+	ExpiresAnnotation = "k8ecr/expires"
+)
+
+// Pull secret actions
+const (
+	SecretCreated = "created"
+	SecretUpdated = "updated"
+	SecretFailed  = "failed"
+)
+
+// RegistryAuth is the login for a registry in a pull secret
+type RegistryAuth struct {
+	Host     string
+	Username string
+	Password string
+}
+
+// DockerConfigJSON returns the .dockerconfigjson of a pull secret for the
+// registries
+func DockerConfigJSON(auths []RegistryAuth) ([]byte, error) {
+	type entry struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	config := struct {
+		Auths map[string]entry `json:"auths"`
+	}{make(map[string]entry)}
+	for _, a := range auths {
+		config.Auths[a.Host] = entry{
+			Username: a.Username,
+			Password: a.Password,
+			Auth:     base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password)),
+		}
+	}
+	return json.Marshal(&config)
+}
+
+// PullSecretChange is what syncing a pull secret did in a namespace
+type PullSecretChange struct {
+	Namespace       string   `json:"namespace"`
+	Action          string   `json:"action"`
+	ServiceAccounts []string `json:"serviceAccounts"`
+	Error           string   `json:"error,omitempty"` // Set if the action failed
+}
+
+// SyncPullSecret creates or updates the pull secret in the namespace and
+// adds it to the imagePullSecrets of the service accounts. Secrets of the
+// same name that k8ecr did not create are left alone.
+func SyncPullSecret(clientset kubernetes.Interface, namespace, name string, config []byte, expires time.Time, serviceAccounts []string) (*PullSecretChange, error) {
+	change := &PullSecretChange{Namespace: namespace, ServiceAccounts: make([]string, 0)}
+	secrets := clientset.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{ManagedByLabel: "k8ecr"},
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+		}
+		change.Action = SecretCreated
+	} else if err != nil {
+		return nil, err
+	} else if secret.Labels[ManagedByLabel] != "k8ecr" {
+		return nil, fmt.Errorf("Secret %s/%s exists and is not managed by k8ecr", namespace, name)
+	} else {
+		change.Action = SecretUpdated
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[ExpiresAnnotation] = expires.UTC().Format(time.RFC3339)
+	secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: config}
+	if change.Action == SecretCreated {
+		_, err = secrets.Create(secret)
+	} else {
+		_, err = secrets.Update(secret)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sa := range serviceAccounts {
+		added, err := addPullSecret(clientset, namespace, sa, name)
+		if err != nil {
+			return nil, err
+		}
+		if added {
+			change.ServiceAccounts = append(change.ServiceAccounts, sa)
+		}
+	}
+	return change, nil
+}
+
+// addPullSecret adds the secret to the imagePullSecrets of the service
+// account, returning whether it was missing
+func addPullSecret(clientset kubernetes.Interface, namespace, serviceAccount, secret string) (bool, error) {
+	accounts := clientset.CoreV1().ServiceAccounts(namespace)
+	sa, err := accounts.Get(serviceAccount, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, ref := range sa.ImagePullSecrets {
+		if ref.Name == secret {
+			return false, nil
+		}
+	}
+	sa.ImagePullSecrets = append(sa.ImagePullSecrets, corev1.LocalObjectReference{Name: secret})
+	_, err = accounts.Update(sa)
+	return err == nil, err
+}
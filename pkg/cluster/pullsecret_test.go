@@ -0,0 +1,67 @@
+package cluster
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDockerConfigJSON(T *testing.T) {
+	data, err := DockerConfigJSON([]RegistryAuth{{Host: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", Username: "AWS", Password: "secret"}})
+	if err != nil {
+		T.Fatal(err)
+	}
+	var config map[string]map[string]map[string]string
+	if err := json.Unmarshal(data, &config); err != nil {
+		T.Fatal(err)
+	}
+	if auth := config["auths"]["123456789012.dkr.ecr.eu-west-1.amazonaws.com"]["auth"]; auth != "QVdTOnNlY3JldA==" {
+		T.Errorf("Unexpected auth %q in %s", auth, data)
+	}
+}
+
+func TestSyncPullSecret(T *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "apps"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "theirs", Namespace: "apps"}},
+	)
+	expires := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	change, err := SyncPullSecret(clientset, "apps", "ecr", []byte("one"), expires, []string{"default"})
+	if err != nil {
+		T.Fatal(err)
+	}
+	if change.Action != SecretCreated || len(change.ServiceAccounts) != 1 {
+		T.Errorf("Unexpected change %+v", change)
+	}
+	change, err = SyncPullSecret(clientset, "apps", "ecr", []byte("two"), expires.Add(time.Hour), []string{"default"})
+	if err != nil {
+		T.Fatal(err)
+	}
+	if change.Action != SecretUpdated || len(change.ServiceAccounts) != 0 {
+		T.Errorf("Unexpected change %+v", change)
+	}
+
+	secret, _ := clientset.CoreV1().Secrets("apps").Get("ecr", metav1.GetOptions{})
+	if secret.Type != corev1.SecretTypeDockerConfigJson || string(secret.Data[corev1.DockerConfigJsonKey]) != "two" {
+		T.Errorf("Unexpected secret %+v", secret)
+	}
+	if secret.Annotations[ExpiresAnnotation] != "2019-01-01T13:00:00Z" {
+		T.Errorf("Unexpected expiry %s", secret.Annotations[ExpiresAnnotation])
+	}
+	sa, _ := clientset.CoreV1().ServiceAccounts("apps").Get("default", metav1.GetOptions{})
+	if len(sa.ImagePullSecrets) != 1 || sa.ImagePullSecrets[0].Name != "ecr" {
+		T.Errorf("Unexpected imagePullSecrets %v", sa.ImagePullSecrets)
+	}
+
+	if _, err := SyncPullSecret(clientset, "apps", "theirs", []byte("one"), expires, nil); err == nil {
+		T.Error("Expected a secret k8ecr does not manage to be left alone")
+	}
+	if _, err := SyncPullSecret(clientset, "apps", "ecr", []byte("one"), expires, []string{"missing"}); err == nil {
+		T.Error("Expected a missing service account to fail")
+	}
+}
@@ -0,0 +1,116 @@
+package ecr
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// Audit checks
+const (
+	CheckGrant     = "grant"
+	CheckWildcard  = "wildcard"
+	CheckScan      = "scan-on-push"
+	CheckLifecycle = "lifecycle"
+)
+
+// Finding is a problem with a repository found by Audit
+type Finding struct {
+	Repository string `json:"repository"`
+	Check      string `json:"check"`
+	Problem    string `json:"problem"`
+	Fixable    bool   `json:"fixable"`
+}
+
+func matchAction(patterns []string, action string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == "ecr:*" || p == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Audit checks that the cluster's principals may pull from the repository,
+// that nobody else may, and that images are scanned and expired. Only Allow
+// statements are read: Deny statements and Conditions are not evaluated, so
+// access they take away is still reported as granted.
+func Audit(name string, state *RepositoryState, cluster string, principals []string) ([]Finding, error) {
+	findings := make([]Finding, 0)
+	policy, err := parsePolicy(state.Policy)
+	if err != nil {
+		return nil, err
+	}
+	statements := make([]*policyStatement, 0, len(policy.statements))
+	for i := range policy.statements {
+		s := &policyStatement{}
+		if err := json.Unmarshal(policy.statements[i], s); err != nil {
+			return nil, err
+		}
+		if s.Effect != "Allow" {
+			continue
+		}
+		statements = append(statements, s)
+		for _, p := range s.principals() {
+			if p == "*" {
+				findings = append(findings, Finding{
+					Repository: name,
+					Check:      CheckWildcard,
+					Problem:    fmt.Sprintf("Statement %q allows any principal", s.Sid),
+				})
+			}
+		}
+	}
+
+	missing := make(map[string]bool)
+	for _, principal := range principals {
+		for _, action := range pullActions {
+			allowed := false
+			for _, s := range statements {
+				for _, p := range s.principals() {
+					if (p == principal || p == "*") && matchAction(s.Action, action) {
+						allowed = true
+					}
+				}
+			}
+			if !allowed {
+				missing[action] = true
+			}
+		}
+	}
+	if len(missing) > 0 {
+		actions := make([]string, 0, len(missing))
+		for a := range missing {
+			actions = append(actions, a)
+		}
+		sort.Strings(actions)
+		findings = append(findings, Finding{
+			Repository: name,
+			Check:      CheckGrant,
+			Problem:    fmt.Sprintf("%s is not allowed %s", cluster, strings.Join(actions, ", ")),
+			Fixable:    true,
+		})
+	}
+
+	repo := state.Repository
+	if repo.ImageScanningConfiguration == nil || !aws.BoolValue(repo.ImageScanningConfiguration.ScanOnPush) {
+		findings = append(findings, Finding{
+			Repository: name,
+			Check:      CheckScan,
+			Problem:    "Images are not scanned on push",
+			Fixable:    true,
+		})
+	}
+	if state.Lifecycle == "" {
+		findings = append(findings, Finding{
+			Repository: name,
+			Check:      CheckLifecycle,
+			Problem:    "No lifecycle policy",
+			Fixable:    true,
+		})
+	}
+	return findings, nil
+}
@@ -0,0 +1,68 @@
+package ecr
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+func checks(findings []Finding) []string {
+	c := make([]string, len(findings))
+	for i, f := range findings {
+		c[i] = f.Check
+	}
+	return c
+}
+
+func TestAudit(T *testing.T) {
+	nodes := []string{"arn:aws:iam::123456789012:role/nodes"}
+	policy, err := mergeStatement(existingPolicy, clusterStatement("prod.example.com", nodes))
+	if err != nil {
+		T.Fatal(err)
+	}
+	state := &RepositoryState{
+		Repository: &ecr.Repository{
+			ImageScanningConfiguration: &ecr.ImageScanningConfiguration{ScanOnPush: aws.Bool(true)},
+		},
+		Lifecycle: `{"rules": []}`,
+		Policy:    policy,
+	}
+	findings, err := Audit("web", state, "prod.example.com", nodes)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(findings) != 0 {
+		T.Errorf("Expected no findings, got %v", findings)
+	}
+
+	state = &RepositoryState{
+		Repository: &ecr.Repository{},
+		Policy:     `{"Statement": [{"Sid": "all", "Effect": "Allow", "Principal": {"AWS": "*"}, "Action": "ecr:BatchGetImage"}]}`,
+	}
+	findings, err = Audit("web", state, "prod.example.com", nodes)
+	if err != nil {
+		T.Fatal(err)
+	}
+	expected := []string{CheckWildcard, CheckGrant, CheckScan, CheckLifecycle}
+	actual := checks(findings)
+	if len(actual) != len(expected) {
+		T.Fatalf("Expected %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			T.Errorf("Expected %s, got %s", expected[i], actual[i])
+		}
+	}
+	if findings[1].Problem != "prod.example.com is not allowed ecr:BatchCheckLayerAvailability, ecr:DescribeImages, ecr:GetDownloadUrlForLayer" {
+		T.Errorf("Unexpected problem %s", findings[1].Problem)
+	}
+	if findings[0].Fixable {
+		T.Error("Wildcard statements should not be fixable")
+	}
+	for _, f := range findings[1:] {
+		if !f.Fixable {
+			T.Errorf("The %s finding should be fixable", f.Check)
+		}
+	}
+}
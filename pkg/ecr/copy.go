@@ -0,0 +1,103 @@
+package ecr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/isotoma/k8ecr/pkg/registry"
+)
+
+// manifestMediaTypes are the manifests BatchGetImage should return unchanged
+var manifestMediaTypes = []*string{
+	aws.String(registry.MediaTypeManifest),
+	aws.String(registry.MediaTypeManifestList),
+	aws.String(registry.MediaTypeOCIManifest),
+	aws.String(registry.MediaTypeOCIIndex),
+}
+
+var registryHost = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+// ParseReference splits [HOST/]REPOSITORY[:TAG|@DIGEST]
+func ParseReference(ref string) (host, repo, reference string) {
+	if parts := strings.SplitN(ref, "/", 2); len(parts) == 2 && strings.ContainsAny(parts[0], ".:") {
+		host, ref = parts[0], parts[1]
+	}
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return host, ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return host, ref[:i], ref[i+1:]
+	}
+	return host, ref, ""
+}
+
+// ClientFor returns a registry client for the ECR registry at host, or the
+// registry of the current account and region if host is empty. The profile,
+// if given, is used instead of the default credentials.
+func ClientFor(host, profile string) (*registry.Client, error) {
+	var region string
+	var accounts []string
+	if host != "" {
+		account, r, ok := RegistryOf(host)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an ECR registry", host)
+		}
+		region, accounts = r, []string{account}
+	}
+	creds, err := Authorize(region, profile, accounts)
+	if err != nil {
+		return nil, err
+	}
+	return registry.New(creds[0].Host, creds[0].Username, creds[0].Password), nil
+}
+
+// Retag adds a tag to an image in the repository without transferring any
+// layers, returning its digest
+func (r *Registry) Retag(name, from, to string) (string, error) {
+	return r.putTag(name, &ecr.ImageIdentifier{ImageTag: aws.String(from)}, from, to)
+}
+
+// TagDigest adds a tag to the image with the digest
+func (r *Registry) TagDigest(name, digest, to string) error {
+	_, err := r.putTag(name, &ecr.ImageIdentifier{ImageDigest: aws.String(digest)}, digest, to)
+	return err
+}
+
+func (r *Registry) putTag(name string, id *ecr.ImageIdentifier, from, to string) (string, error) {
+	response, err := r.service.BatchGetImage(&ecr.BatchGetImageInput{
+		RepositoryName:     aws.String(name),
+		ImageIds:           []*ecr.ImageIdentifier{id},
+		AcceptedMediaTypes: manifestMediaTypes,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(response.Images) == 0 {
+		if len(response.Failures) > 0 {
+			return "", fmt.Errorf("%s:%s: %s", name, from, aws.StringValue(response.Failures[0].FailureReason))
+		}
+		return "", fmt.Errorf("%s:%s not found", name, from)
+	}
+	image := response.Images[0]
+	digest := aws.StringValue(image.ImageId.ImageDigest)
+	_, err = r.service.PutImage(&ecr.PutImageInput{
+		RepositoryName:         aws.String(name),
+		ImageManifest:          image.ImageManifest,
+		ImageManifestMediaType: image.ImageManifestMediaType,
+		ImageTag:               aws.String(to),
+	})
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case ecr.ErrCodeImageAlreadyExistsException:
+			// The tag is already on this image
+			return digest, nil
+		case ecr.ErrCodeImageTagAlreadyExistsException:
+			return "", fmt.Errorf("%s:%s already exists and the repository's tags are immutable", name, to)
+		}
+	}
+	return digest, err
+}
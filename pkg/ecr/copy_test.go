@@ -0,0 +1,25 @@
+package ecr
+
+import "testing"
+
+func TestParseReference(T *testing.T) {
+	tests := []struct{ ref, host, repo, reference string }{
+		{"app", "", "app", ""},
+		{"app:1.0.0", "", "app", "1.0.0"},
+		{"team/app@sha256:abc", "", "team/app", "sha256:abc"},
+		{"123456789012.dkr.ecr.eu-west-1.amazonaws.com/team/app:1.0.0", "123456789012.dkr.ecr.eu-west-1.amazonaws.com", "team/app", "1.0.0"},
+		{"localhost:5000/app", "localhost:5000", "app", ""},
+	}
+	for _, t := range tests {
+		host, repo, reference := ParseReference(t.ref)
+		if host != t.host || repo != t.repo || reference != t.reference {
+			T.Errorf("%s: expected %s %s %s, got %s %s %s", t.ref, t.host, t.repo, t.reference, host, repo, reference)
+		}
+	}
+	if registryHost.FindStringSubmatch("123456789012.dkr.ecr.eu-west-1.amazonaws.com")[3] != "eu-west-1" {
+		T.Error("Expected the region to be found")
+	}
+	if registryHost.MatchString("docker.io") {
+		T.Error("Expected docker.io not to be an ECR registry")
+	}
+}
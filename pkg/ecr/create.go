@@ -1,62 +1,14 @@
 package ecr
 
 import (
-	"encoding/json"
 	"fmt"
-	"os/exec"
-	"strings"
+	"sort"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ecr"
-	"github.com/aws/aws-sdk-go/service/iam"
 )
 
-// Get the context name for the current cluster
-// This is used to find the AWS roles for the cluster
-// This is how kops configures things specifically
-func getContext() string {
-	cmd := exec.Command("kubectl", "config", "current-context")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return ""
-	}
-	context := strings.TrimSpace(string(output))
-	return context
-}
-func getClusterRole(cluster string, role string) string {
-	svc := iam.New(createSession())
-	name := fmt.Sprintf("%s.%s", role, cluster)
-	input := &iam.GetRoleInput{
-		RoleName: &name,
-	}
-	result, err := svc.GetRole(input)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case iam.ErrCodeNoSuchEntityException:
-				fmt.Println(iam.ErrCodeNoSuchEntityException, aerr.Error())
-			case iam.ErrCodeServiceFailureException:
-				fmt.Println(iam.ErrCodeServiceFailureException, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
-		return ""
-	}
-	return *result.Role.Arn
-}
-
-func getPrincipals(cluster string) []string {
-	masters := getClusterRole(cluster, "masters")
-	nodes := getClusterRole(cluster, "nodes")
-	return []string{masters, nodes}
-}
-
 // PrincipalEntry in an IAM Policy
 type PrincipalEntry struct {
 	AWS []string
@@ -76,46 +28,210 @@ type PolicyDocument struct {
 	Statement []StatementEntry
 }
 
-// CreateRepository creates the named repository
-func (r *Registry) CreateRepository(name string) (*ecr.Repository, error) {
-	context := getContext()
-	principals := getPrincipals(context)
-	policy := PolicyDocument{
-		Version: "2008-10-17",
-		Statement: []StatementEntry{
-			StatementEntry{
-				Sid:    "Cluster access",
-				Effect: "Allow",
-				Principal: PrincipalEntry{
-					AWS: principals,
-				},
-				Action: []string{
-					"ecr:GetDownloadUrlForLayer",
-					"ecr:BatchGetImage",
-					"ecr:BatchCheckLayerAvailability",
-					"ecr:DescribeImages",
-				},
-			},
-		},
+// RepositoryOptions are the settings applied to a repository when it is
+// created, and converged if it already exists. Settings left nil are not
+// changed on existing repositories.
+type RepositoryOptions struct {
+	ScanOnPush    *bool             `yaml:"scanOnPush"`
+	ImmutableTags *bool             `yaml:"immutableTags"`
+	KMS           bool              `yaml:"kms"`
+	KMSKey        string            `yaml:"kmsKey"`
+	Tags          map[string]string `yaml:"tags"`
+	Lifecycle     []string          `yaml:"lifecycle"` // Lifecycle templates, see LifecyclePolicy
+}
+
+func (o RepositoryOptions) mutability() string {
+	if aws.BoolValue(o.ImmutableTags) {
+		return ecr.ImageTagMutabilityImmutable
 	}
+	return ecr.ImageTagMutabilityMutable
+}
 
-	b, err := json.Marshal(&policy)
+func (o RepositoryOptions) encryption() *ecr.EncryptionConfiguration {
+	if o.KMS || o.KMSKey != "" {
+		config := &ecr.EncryptionConfiguration{
+			EncryptionType: aws.String(ecr.EncryptionTypeKms),
+		}
+		if o.KMSKey != "" {
+			config.KmsKey = aws.String(o.KMSKey)
+		}
+		return config
+	}
+	return &ecr.EncryptionConfiguration{
+		EncryptionType: aws.String(ecr.EncryptionTypeAes256),
+	}
+}
+
+func (o RepositoryOptions) tags() []*ecr.Tag {
+	keys := make([]string, 0, len(o.Tags))
+	for k := range o.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]*ecr.Tag, len(keys))
+	for i, k := range keys {
+		tags[i] = &ecr.Tag{Key: aws.String(k), Value: aws.String(o.Tags[k])}
+	}
+	return tags
+}
+
+func (r *Registry) describeRepository(name string) (*ecr.Repository, error) {
+	response, err := r.service.DescribeRepositories(&ecr.DescribeRepositoriesInput{
+		RepositoryNames: []*string{aws.String(name)},
+	})
 	if err != nil {
 		return nil, err
 	}
+	return response.Repositories[0], nil
+}
 
-	resp, createErr := r.service.CreateRepository(&ecr.CreateRepositoryInput{
-		RepositoryName: &name,
+// PutScanOnPush turns scanning images when they are pushed on or off
+func (r *Registry) PutScanOnPush(name string, scanOnPush bool) error {
+	_, err := r.service.PutImageScanningConfiguration(&ecr.PutImageScanningConfigurationInput{
+		RepositoryName: aws.String(name),
+		ImageScanningConfiguration: &ecr.ImageScanningConfiguration{
+			ScanOnPush: aws.Bool(scanOnPush),
+		},
 	})
+	return err
+}
+
+// Exists returns whether the repository exists
+func (r *Registry) Exists(name string) (bool, error) {
+	_, err := r.describeRepository(name)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeRepositoryNotFoundException {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// scanOnPush returns whether the repository scans images when they are pushed
+func scanOnPush(repo *ecr.Repository) bool {
+	return repo.ImageScanningConfiguration != nil && aws.BoolValue(repo.ImageScanningConfiguration.ScanOnPush)
+}
 
-	if createErr != nil {
+// converge updates the settings of an existing repository to match the
+// options that are set. Encryption cannot be changed once a repository is
+// created.
+func (r *Registry) converge(repo *ecr.Repository, options RepositoryOptions) error {
+	if options.ScanOnPush != nil && scanOnPush(repo) != *options.ScanOnPush {
+		fmt.Printf("Setting scan on push to %v\n", *options.ScanOnPush)
+		if err := r.PutScanOnPush(aws.StringValue(repo.RepositoryName), *options.ScanOnPush); err != nil {
+			return err
+		}
+	}
+	if options.ImmutableTags != nil && aws.StringValue(repo.ImageTagMutability) != options.mutability() {
+		fmt.Printf("Setting tag mutability to %s\n", options.mutability())
+		_, err := r.service.PutImageTagMutability(&ecr.PutImageTagMutabilityInput{
+			RepositoryName:     repo.RepositoryName,
+			ImageTagMutability: aws.String(options.mutability()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	wanted := options.encryption()
+	if actual := repo.EncryptionConfiguration; actual != nil {
+		typeDiffers := aws.StringValue(actual.EncryptionType) != aws.StringValue(wanted.EncryptionType)
+		keyDiffers := wanted.KmsKey != nil && aws.StringValue(actual.KmsKey) != aws.StringValue(wanted.KmsKey)
+		if typeDiffers || keyDiffers {
+			fmt.Printf("Warning: %s is encrypted with %s %s, which cannot be changed\n",
+				aws.StringValue(repo.RepositoryName), aws.StringValue(actual.EncryptionType), aws.StringValue(actual.KmsKey))
+		}
+	}
+	if len(options.Tags) > 0 {
+		response, err := r.service.ListTagsForResource(&ecr.ListTagsForResourceInput{
+			ResourceArn: repo.RepositoryArn,
+		})
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]string)
+		for _, t := range response.Tags {
+			existing[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+		}
+		changed := make([]*ecr.Tag, 0)
+		for _, t := range options.tags() {
+			if value, ok := existing[*t.Key]; !ok || value != *t.Value {
+				changed = append(changed, t)
+			}
+		}
+		if len(changed) > 0 {
+			fmt.Printf("Tagging with %d tags\n", len(changed))
+			_, err := r.service.TagResource(&ecr.TagResourceInput{
+				ResourceArn: repo.RepositoryArn,
+				Tags:        changed,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EnsureRepository creates the named repository with the options, or
+// converges its settings if it already exists
+func (r *Registry) EnsureRepository(name string, options RepositoryOptions) (*ecr.Repository, error) {
+	lifecycle := ""
+	if len(options.Lifecycle) > 0 {
+		var err error
+		lifecycle, err = LifecyclePolicy(options.Lifecycle)
+		if err != nil {
+			return nil, err
+		}
+	}
+	input := &ecr.CreateRepositoryInput{
+		RepositoryName: &name,
+		ImageScanningConfiguration: &ecr.ImageScanningConfiguration{
+			ScanOnPush: aws.Bool(aws.BoolValue(options.ScanOnPush)),
+		},
+		ImageTagMutability:      aws.String(options.mutability()),
+		EncryptionConfiguration: options.encryption(),
+	}
+	if len(options.Tags) > 0 {
+		input.Tags = options.tags()
+	}
+	resp, createErr := r.service.CreateRepository(input)
+
+	var repo *ecr.Repository
+	if aerr, ok := createErr.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeRepositoryAlreadyExistsException {
+		fmt.Printf("Repository %s already exists, updating it\n", name)
+		existing, err := r.describeRepository(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.converge(existing, options); err != nil {
+			return nil, err
+		}
+		repo = existing
+	} else if createErr != nil {
 		return nil, createErr
+	} else {
+		repo = resp.Repository
 	}
 
-	var repo = resp.Repository
-	r.service.SetRepositoryPolicy(&ecr.SetRepositoryPolicyInput{
-		RepositoryName: aws.String(name),
-		PolicyText:     aws.String(string(b)),
-	})
+	if lifecycle != "" {
+		if err := r.PutLifecyclePolicy(name, lifecycle); err != nil {
+			return repo, err
+		}
+	}
+	return repo, nil
+}
+
+// CreateRepository creates the named repository with the options, granting
+// the principals of the cluster read access. If the repository already
+// exists its settings and policy are converged instead.
+func (r *Registry) CreateRepository(name string, options RepositoryOptions, cluster string, principals []string) (*ecr.Repository, error) {
+	if err := checkPrincipals(principals); err != nil {
+		return nil, err
+	}
+	repo, err := r.EnsureRepository(name, options)
+	if err != nil {
+		return repo, err
+	}
+	if err := r.Grant(name, cluster, principals); err != nil {
+		return repo, err
+	}
 	return repo, nil
 }
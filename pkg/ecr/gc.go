@@ -0,0 +1,157 @@
+package ecr
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/isotoma/k8ecr/pkg/registry"
+)
+
+// Image is an image in a repository
+type Image struct {
+	Digest   string
+	Tags     []string
+	PushedAt time.Time
+}
+
+// Images returns every image in the repository, newest first
+func (r *Registry) Images(name string) ([]Image, error) {
+	images := make([]Image, 0)
+	err := r.service.DescribeImagesPages(&ecr.DescribeImagesInput{
+		RepositoryName: aws.String(name),
+	}, func(page *ecr.DescribeImagesOutput, lastPage bool) bool {
+		for _, i := range page.ImageDetails {
+			images = append(images, Image{
+				Digest:   aws.StringValue(i.ImageDigest),
+				Tags:     aws.StringValueSlice(i.ImageTags),
+				PushedAt: aws.TimeValue(i.ImagePushedAt),
+			})
+		}
+		return true
+	})
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].PushedAt.After(images[j].PushedAt)
+	})
+	return images, err
+}
+
+// ManifestLists returns the digests of the images that each manifest list
+// among the images refers to, by the list's digest. Those images are
+// untagged, so if every image is tagged no manifests are fetched.
+func (r *Registry) ManifestLists(name string, images []Image) (map[string][]string, error) {
+	const batchSize = 100
+	lists := make(map[string][]string)
+	untagged := false
+	ids := make([]*ecr.ImageIdentifier, len(images))
+	for i, image := range images {
+		untagged = untagged || len(image.Tags) == 0
+		ids[i] = &ecr.ImageIdentifier{ImageDigest: aws.String(image.Digest)}
+	}
+	if !untagged {
+		return lists, nil
+	}
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		response, err := r.service.BatchGetImage(&ecr.BatchGetImageInput{
+			RepositoryName:     aws.String(name),
+			ImageIds:           ids[start:end],
+			AcceptedMediaTypes: manifestMediaTypes,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range response.Images {
+			raw := registry.RawManifest{
+				MediaType: aws.StringValue(image.ImageManifestMediaType),
+				Body:      []byte(aws.StringValue(image.ImageManifest)),
+			}
+			m, err := raw.Parse()
+			if err != nil {
+				return nil, fmt.Errorf("%s@%s: %s", name, aws.StringValue(image.ImageId.ImageDigest), err)
+			}
+			if m.IsList() {
+				digest := aws.StringValue(image.ImageId.ImageDigest)
+				for _, child := range m.Manifests {
+					lists[digest] = append(lists[digest], child.Digest)
+				}
+			}
+		}
+	}
+	return lists, nil
+}
+
+// SelectGarbage returns the images, sorted newest first, that are not
+// referenced by any of their tags or their digest, were pushed before the
+// cutoff, are not among the newest images to keep, and are not in any of
+// the manifest lists that are kept
+func SelectGarbage(images []Image, referenced map[string]bool, lists map[string][]string, cutoff time.Time, keep int) []Image {
+	candidates := make(map[string]bool)
+	for i, image := range images {
+		if i < keep || !image.PushedAt.Before(cutoff) || referenced[image.Digest] {
+			continue
+		}
+		inUse := false
+		for _, t := range image.Tags {
+			inUse = inUse || referenced[t]
+		}
+		if !inUse {
+			candidates[image.Digest] = true
+		}
+	}
+	// Lists that are kept keep their images, which may be lists themselves
+	for changed := true; changed; {
+		changed = false
+		for list, children := range lists {
+			if candidates[list] {
+				continue
+			}
+			for _, child := range children {
+				if candidates[child] {
+					delete(candidates, child)
+					changed = true
+				}
+			}
+		}
+	}
+	garbage := make([]Image, 0)
+	for _, image := range images {
+		if candidates[image.Digest] {
+			garbage = append(garbage, image)
+		}
+	}
+	return garbage
+}
+
+// DeleteImages deletes the images from the repository by digest
+func (r *Registry) DeleteImages(name string, images []Image) error {
+	const batchSize = 100
+	for start := 0; start < len(images); start += batchSize {
+		end := start + batchSize
+		if end > len(images) {
+			end = len(images)
+		}
+		ids := make([]*ecr.ImageIdentifier, 0, batchSize)
+		for _, image := range images[start:end] {
+			ids = append(ids, &ecr.ImageIdentifier{ImageDigest: aws.String(image.Digest)})
+		}
+		response, err := r.service.BatchDeleteImage(&ecr.BatchDeleteImageInput{
+			RepositoryName: aws.String(name),
+			ImageIds:       ids,
+		})
+		if err != nil {
+			return err
+		}
+		if len(response.Failures) > 0 {
+			f := response.Failures[0]
+			return fmt.Errorf("Failed to delete %d images from %s, including %s: %s",
+				len(response.Failures), name, aws.StringValue(f.ImageId.ImageDigest), aws.StringValue(f.FailureReason))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package ecr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectGarbage(T *testing.T) {
+	now := time.Now()
+	days := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	images := []Image{
+		{Digest: "sha256:7", Tags: []string{"0.1.0"}, PushedAt: days(5)},
+		{Digest: "sha256:1", Tags: []string{"1.0.4"}, PushedAt: days(40)},
+		{Digest: "sha256:2", Tags: []string{"1.0.3"}, PushedAt: days(50)},
+		{Digest: "sha256:3", Tags: []string{"1.0.2", "stable"}, PushedAt: days(60)},
+		{Digest: "sha256:4", Tags: []string{}, PushedAt: days(70)},
+		{Digest: "sha256:5", Tags: []string{"1.0.0"}, PushedAt: days(80)},
+		{Digest: "sha256:6", Tags: []string{"0.9.0"}, PushedAt: days(90)},
+		{Digest: "sha256:8", Tags: []string{}, PushedAt: days(90)},
+	}
+	referenced := map[string]bool{"stable": true, "sha256:5": true}
+	lists := map[string][]string{"sha256:1": {"sha256:8"}, "sha256:6": {"sha256:4"}}
+	garbage := SelectGarbage(images, referenced, lists, days(30), 2)
+	digests := make([]string, len(garbage))
+	for i, g := range garbage {
+		digests[i] = g.Digest
+	}
+	// sha256:4 is only in a list that is deleted itself
+	expected := []string{"sha256:2", "sha256:4", "sha256:6"}
+	if len(digests) != len(expected) {
+		T.Fatalf("SelectGarbage is wrong: %v", digests)
+	}
+	for i := range expected {
+		if digests[i] != expected[i] {
+			T.Errorf("SelectGarbage is wrong: %v", digests)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package ecr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HelperName is the name the Docker client runs a credential helper by
+const HelperName = "docker-credential-k8ecr"
+
+// errCredentialsNotFound is the message the Docker client expects when a
+// helper has no credentials for a server
+var errCredentialsNotFound = errors.New("credentials not found in native keychain")
+
+// refreshBefore is how long before they expire cached credentials are renewed
+const refreshBefore = 5 * time.Minute
+
+// DefaultCachePath returns the file credentials are cached in
+func DefaultCachePath() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "k8ecr", "credentials.json")
+	}
+	return filepath.Join(homeDir(), ".cache", "k8ecr", "credentials.json")
+}
+
+// CredentialCache keeps credentials in a file, by registry host
+type CredentialCache struct {
+	Path    string
+	entries map[string]*Credentials
+}
+
+// LoadCredentialCache reads the cache, which is empty if the file does not
+// exist or cannot be read
+func LoadCredentialCache(path string) *CredentialCache {
+	c := &CredentialCache{Path: path, entries: make(map[string]*Credentials)}
+	if data, err := ioutil.ReadFile(path); err == nil {
+		// A corrupt cache is only a cache miss
+		json.Unmarshal(data, &c.entries)
+	}
+	return c
+}
+
+// Get returns the credentials for the host if they are still valid
+func (c *CredentialCache) Get(host string, now time.Time) *Credentials {
+	creds, ok := c.entries[host]
+	if !ok || !now.Add(refreshBefore).Before(creds.ExpiresAt) {
+		return nil
+	}
+	return creds
+}
+
+// Put adds the credentials for the host, dropping any that have expired
+func (c *CredentialCache) Put(host string, creds *Credentials, now time.Time) {
+	for h, e := range c.entries {
+		if !now.Before(e.ExpiresAt) {
+			delete(c.entries, h)
+		}
+	}
+	c.entries[host] = creds
+}
+
+// Erase removes the credentials for the host
+func (c *CredentialCache) Erase(host string) {
+	delete(c.entries, host)
+}
+
+// Save writes the cache, readable only by the user. It is written to a
+// temporary file first, so helpers running at once never see half a cache.
+func (c *CredentialCache) Save() error {
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(c.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(c.Path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0600)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.Path)
+}
+
+// CredentialHelper answers the Docker credential helper protocol for ECR
+// registries, caching credentials until they expire
+type CredentialHelper struct {
+	Cache     string
+	Authorize func(account, region string) (*Credentials, error)
+	Now       func() time.Time
+}
+
+// NewCredentialHelper returns a helper using the default credentials and cache
+func NewCredentialHelper() *CredentialHelper {
+	return &CredentialHelper{
+		Cache: DefaultCachePath(),
+		Authorize: func(account, region string) (*Credentials, error) {
+			creds, err := Authorize(region, "", []string{account})
+			if err != nil {
+				return nil, err
+			}
+			return creds[0], nil
+		},
+		Now: time.Now,
+	}
+}
+
+// helperCredentials is the JSON the Docker client exchanges with helpers
+type helperCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+func readServer(in io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Run performs the action, reading its input from in and writing its
+// output to out
+func (h *CredentialHelper) Run(action string, in io.Reader, out io.Writer) error {
+	switch action {
+	case "get":
+		server, err := readServer(in)
+		if err != nil {
+			return err
+		}
+		creds, err := h.Get(server)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(out).Encode(&helperCredentials{
+			ServerURL: server,
+			Username:  creds.Username,
+			Secret:    creds.Password,
+		})
+	case "store":
+		// ECR issues its own credentials, so there is nothing to store
+		_, err := ioutil.ReadAll(in)
+		return err
+	case "erase":
+		server, err := readServer(in)
+		if err != nil {
+			return err
+		}
+		cache := LoadCredentialCache(h.Cache)
+		cache.Erase(serverHost(server))
+		return cache.Save()
+	case "list":
+		cache := LoadCredentialCache(h.Cache)
+		now := h.Now()
+		servers := make(map[string]string)
+		for host := range cache.entries {
+			if creds := cache.Get(host, now); creds != nil {
+				servers[host] = creds.Username
+			}
+		}
+		return json.NewEncoder(out).Encode(servers)
+	}
+	return fmt.Errorf("Unknown credential helper action %s, expected get, store, erase or list", action)
+}
+
+// Get returns the credentials for the server, from the cache if they are
+// still valid
+func (h *CredentialHelper) Get(server string) (*Credentials, error) {
+	account, region, ok := RegistryOf(server)
+	if !ok {
+		return nil, errCredentialsNotFound
+	}
+	host := serverHost(server)
+	cache := LoadCredentialCache(h.Cache)
+	now := h.Now()
+	if creds := cache.Get(host, now); creds != nil {
+		return creds, nil
+	}
+	creds, err := h.Authorize(account, region)
+	if err != nil {
+		return nil, err
+	}
+	cache.Put(host, creds, now)
+	// The credentials are still good if they cannot be cached
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot cache credentials: %s\n", err)
+	}
+	return creds, nil
+}
@@ -0,0 +1,171 @@
+package ecr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+type lifecycleSelection struct {
+	TagStatus      string   `json:"tagStatus"`
+	TagPatternList []string `json:"tagPatternList,omitempty"`
+	CountType      string   `json:"countType"`
+	CountUnit      string   `json:"countUnit,omitempty"`
+	CountNumber    int      `json:"countNumber"`
+}
+
+type lifecycleAction struct {
+	Type string `json:"type"`
+}
+
+type lifecycleRule struct {
+	RulePriority int                `json:"rulePriority"`
+	Description  string             `json:"description"`
+	Selection    lifecycleSelection `json:"selection"`
+	Action       lifecycleAction    `json:"action"`
+}
+
+type lifecyclePolicy struct {
+	Rules []lifecycleRule `json:"rules"`
+}
+
+// LifecycleTemplate builds a lifecycle rule from a number of images or days
+type LifecycleTemplate struct {
+	Name        string
+	Description string
+	Default     int
+	selection   func(n int) lifecycleSelection
+}
+
+// LifecycleTemplates are the named lifecycle rules that can be applied
+var LifecycleTemplates = []LifecycleTemplate{
+	{
+		Name:        "expire-untagged",
+		Description: "Expire untagged images after N days",
+		Default:     7,
+		selection: func(n int) lifecycleSelection {
+			return lifecycleSelection{TagStatus: "untagged", CountType: "sinceImagePushed", CountUnit: "days", CountNumber: n}
+		},
+	},
+	{
+		Name:        "keep-semver",
+		Description: "Keep the last N images tagged with semantic versions",
+		Default:     20,
+		selection: func(n int) lifecycleSelection {
+			return lifecycleSelection{TagStatus: "tagged", TagPatternList: []string{"*.*.*"}, CountType: "imageCountMoreThan", CountNumber: n}
+		},
+	},
+	{
+		Name:        "keep-last",
+		Description: "Keep the last N images",
+		Default:     50,
+		selection: func(n int) lifecycleSelection {
+			return lifecycleSelection{TagStatus: "any", CountType: "imageCountMoreThan", CountNumber: n}
+		},
+	},
+}
+
+func findLifecycleTemplate(name string) (*LifecycleTemplate, error) {
+	for i := range LifecycleTemplates {
+		if LifecycleTemplates[i].Name == name {
+			return &LifecycleTemplates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown lifecycle template %s", name)
+}
+
+// LifecyclePolicy builds a lifecycle policy from templates, each given as
+// NAME or NAME:N. Rules selecting any image must come last, so they do.
+func LifecyclePolicy(specs []string) (string, error) {
+	rules := make([]lifecycleRule, 0)
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		template, err := findLifecycleTemplate(parts[0])
+		if err != nil {
+			return "", err
+		}
+		n := template.Default
+		if len(parts) == 2 {
+			n, err = strconv.Atoi(parts[1])
+			if err != nil || n < 1 {
+				return "", fmt.Errorf("Lifecycle template %s needs a positive number, not %s", parts[0], parts[1])
+			}
+		}
+		rules = append(rules, lifecycleRule{
+			Description: strings.Replace(template.Description, "N", strconv.Itoa(n), 1),
+			Selection:   template.selection(n),
+			Action:      lifecycleAction{Type: "expire"},
+		})
+	}
+	if len(rules) == 0 {
+		return "", errors.New("No lifecycle templates given")
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Selection.TagStatus != "any" && rules[j].Selection.TagStatus == "any"
+	})
+	for i := range rules {
+		rules[i].RulePriority = i + 1
+	}
+	b, err := json.Marshal(lifecyclePolicy{Rules: rules})
+	return string(b), err
+}
+
+// PutLifecyclePolicy applies the lifecycle policy to the repository
+func (r *Registry) PutLifecyclePolicy(name, policy string) error {
+	_, err := r.service.PutLifecyclePolicy(&ecr.PutLifecyclePolicyInput{
+		RepositoryName:      aws.String(name),
+		LifecyclePolicyText: aws.String(policy),
+	})
+	return err
+}
+
+// LifecyclePreview is an image the lifecycle policy would act on
+type LifecyclePreview struct {
+	Digest   string
+	Tags     []string
+	PushedAt time.Time
+	Action   string
+	Rule     int64
+}
+
+// PreviewLifecyclePolicy asks ECR which images the lifecycle policy would
+// act on, without applying it
+func (r *Registry) PreviewLifecyclePolicy(name, policy string) ([]LifecyclePreview, error) {
+	_, err := r.service.StartLifecyclePolicyPreview(&ecr.StartLifecyclePolicyPreviewInput{
+		RepositoryName:      aws.String(name),
+		LifecyclePolicyText: aws.String(policy),
+	})
+	if err != nil {
+		return nil, err
+	}
+	input := &ecr.GetLifecyclePolicyPreviewInput{
+		RepositoryName: aws.String(name),
+	}
+	if err := r.service.WaitUntilLifecyclePolicyPreviewComplete(input); err != nil {
+		return nil, err
+	}
+	previews := make([]LifecyclePreview, 0)
+	err = r.service.GetLifecyclePolicyPreviewPages(input, func(page *ecr.GetLifecyclePolicyPreviewOutput, lastPage bool) bool {
+		for _, result := range page.PreviewResults {
+			preview := LifecyclePreview{
+				Digest:   aws.StringValue(result.ImageDigest),
+				Tags:     aws.StringValueSlice(result.ImageTags),
+				PushedAt: aws.TimeValue(result.ImagePushedAt),
+				Rule:     aws.Int64Value(result.AppliedRulePriority),
+			}
+			if result.Action != nil {
+				preview.Action = aws.StringValue(result.Action.Type)
+			}
+			previews = append(previews, preview)
+		}
+		return true
+	})
+	return previews, err
+}
@@ -0,0 +1,33 @@
+package ecr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLifecyclePolicy(T *testing.T) {
+	text, err := LifecyclePolicy([]string{"keep-last", "keep-semver:10", "expire-untagged"})
+	if err != nil {
+		T.Fatal(err)
+	}
+	policy := lifecyclePolicy{}
+	if err := json.Unmarshal([]byte(text), &policy); err != nil {
+		T.Fatal(err)
+	}
+	if len(policy.Rules) != 3 {
+		T.Fatalf("Expected 3 rules: %s", text)
+	}
+	last := policy.Rules[2]
+	if last.Selection.TagStatus != "any" || last.RulePriority != 3 || last.Selection.CountNumber != 50 {
+		T.Errorf("Rule selecting any image should come last: %v", last)
+	}
+	semver := policy.Rules[0]
+	if semver.Selection.CountNumber != 10 || semver.RulePriority != 1 || semver.Description != "Keep the last 10 images tagged with semantic versions" {
+		T.Errorf("keep-semver rule is wrong: %v", semver)
+	}
+	for _, specs := range [][]string{{}, {"keep-forever"}, {"keep-last:0"}, {"keep-last:x"}} {
+		if _, err := LifecyclePolicy(specs); err == nil {
+			T.Errorf("Expected %v to be refused", specs)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package ecr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// Credentials are the Docker credentials for an ECR registry, which are
+// valid until they expire
+type Credentials struct {
+	Host      string    `json:"host"`
+	Username  string    `json:"username"`
+	Password  string    `json:"password"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func homeDir() string {
+	if h := os.Getenv("HOME"); h != "" {
+		return h
+	}
+	return os.Getenv("USERPROFILE") // windows
+}
+
+// serverHost strips the scheme and path from a registry given as a URL
+func serverHost(server string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
+
+// RegistryOf returns the account and region of an ECR registry host, which
+// may be given as a URL
+func RegistryOf(host string) (account, region string, ok bool) {
+	m := registryHost.FindStringSubmatch(serverHost(host))
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[3], true
+}
+
+// authorization exchanges an authorization token for the credentials of
+// each registry in the input
+func authorization(svc *ecr.ECR, input *ecr.GetAuthorizationTokenInput) ([]*Credentials, error) {
+	response, err := svc.GetAuthorizationToken(input)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Credentials, 0, len(response.AuthorizationData))
+	for _, data := range response.AuthorizationData {
+		token, err := base64.StdEncoding.DecodeString(aws.StringValue(data.AuthorizationToken))
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.SplitN(string(token), ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("Malformed authorization token")
+		}
+		result = append(result, &Credentials{
+			Host:      strings.TrimPrefix(aws.StringValue(data.ProxyEndpoint), "https://"),
+			Username:  parts[0],
+			Password:  parts[1],
+			ExpiresAt: aws.TimeValue(data.ExpiresAt),
+		})
+	}
+	if len(result) == 0 {
+		return nil, errors.New("No authorization data returned")
+	}
+	return result, nil
+}
+
+// Authorize returns the credentials for the registries of the accounts in
+// the region. With no accounts this is the current account's registry, with
+// no region the default region, and with no profile the default credentials.
+func Authorize(region, profile string, accounts []string) ([]*Credentials, error) {
+	config := aws.NewConfig()
+	if region != "" {
+		config = config.WithRegion(region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		Profile:           profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	input := &ecr.GetAuthorizationTokenInput{}
+	if len(accounts) > 0 {
+		input.RegistryIds = aws.StringSlice(accounts)
+	}
+	return authorization(ecr.New(sess), input)
+}
+
+// DockerConfigPath returns the Docker client's config file
+func DockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	return filepath.Join(homeDir(), ".docker", "config.json")
+}
+
+// WriteDockerConfig logs the Docker client in to each registry, keeping
+// everything else in the config file. If helper is set the registries are
+// given that credential helper instead of the credentials themselves.
+func WriteDockerConfig(path string, creds []*Credentials, helper string) error {
+	config := make(map[string]json.RawMessage)
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("Cannot read %s: %s", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	auths := make(map[string]json.RawMessage)
+	helpers := make(map[string]string)
+	if raw, ok := config["auths"]; ok {
+		if err := json.Unmarshal(raw, &auths); err != nil {
+			return fmt.Errorf("Cannot read the auths in %s: %s", path, err)
+		}
+	}
+	if raw, ok := config["credHelpers"]; ok {
+		if err := json.Unmarshal(raw, &helpers); err != nil {
+			return fmt.Errorf("Cannot read the credHelpers in %s: %s", path, err)
+		}
+	}
+	for _, c := range creds {
+		// aws ecr get-login writes the registry as a URL
+		delete(auths, "https://"+c.Host)
+		if helper != "" {
+			delete(auths, c.Host)
+			helpers[c.Host] = helper
+			continue
+		}
+		delete(helpers, c.Host)
+		auth, _ := json.Marshal(map[string]string{
+			"auth": base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.Password)),
+		})
+		auths[c.Host] = auth
+	}
+	if config["auths"], err = json.Marshal(auths); err != nil {
+		return err
+	}
+	if len(helpers) > 0 {
+		if config["credHelpers"], err = json.Marshal(helpers); err != nil {
+			return err
+		}
+	} else {
+		delete(config, "credHelpers")
+	}
+	data, err = json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
@@ -0,0 +1,159 @@
+package ecr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHost = "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+
+func TestRegistryOf(T *testing.T) {
+	for _, server := range []string{testHost, "https://" + testHost, "https://" + testHost + "/v2/"} {
+		account, region, ok := RegistryOf(server)
+		if !ok || account != "123456789012" || region != "eu-west-1" {
+			T.Errorf("%s: got %s %s %v", server, account, region, ok)
+		}
+	}
+	if _, _, ok := RegistryOf("index.docker.io"); ok {
+		T.Error("Expected Docker Hub not to be an ECR registry")
+	}
+}
+
+func TestWriteDockerConfig(T *testing.T) {
+	dir, err := ioutil.TempDir("", "k8ecr")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	existing := `{"auths": {"https://` + testHost + `": {"auth": "b2xk"}, "quay.io": {"auth": "cXVheQ=="}}, "psFormat": "table"}`
+	if err := ioutil.WriteFile(path, []byte(existing), 0600); err != nil {
+		T.Fatal(err)
+	}
+	creds := []*Credentials{{Host: testHost, Username: "AWS", Password: "secret"}}
+
+	read := func() map[string]map[string]interface{} {
+		config := make(map[string]map[string]interface{})
+		data, _ := ioutil.ReadFile(path)
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			T.Fatal(err)
+		}
+		if string(raw["psFormat"]) != `"table"` {
+			T.Errorf("Expected other settings to be kept, got %s", data)
+		}
+		delete(raw, "psFormat")
+		for k, v := range raw {
+			m := make(map[string]interface{})
+			json.Unmarshal(v, &m)
+			config[k] = m
+		}
+		return config
+	}
+
+	if err := WriteDockerConfig(path, creds, ""); err != nil {
+		T.Fatal(err)
+	}
+	config := read()
+	if len(config["auths"]) != 2 || config["auths"]["quay.io"] == nil {
+		T.Errorf("Expected the stale URL entry to be replaced, got %v", config["auths"])
+	}
+	if auth := config["auths"][testHost].(map[string]interface{})["auth"]; auth != "QVdTOnNlY3JldA==" {
+		T.Errorf("Unexpected auth %v", auth)
+	}
+
+	if err := WriteDockerConfig(path, creds, "k8ecr"); err != nil {
+		T.Fatal(err)
+	}
+	config = read()
+	if _, ok := config["auths"][testHost]; ok {
+		T.Error("Expected the credentials to be removed in favour of the helper")
+	}
+	if config["credHelpers"][testHost] != "k8ecr" {
+		T.Errorf("Expected the helper to be configured, got %v", config["credHelpers"])
+	}
+}
+
+func TestCredentialHelper(T *testing.T) {
+	dir, err := ioutil.TempDir("", "k8ecr")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	h := &CredentialHelper{
+		Cache: filepath.Join(dir, "cache", "credentials.json"),
+		Authorize: func(account, region string) (*Credentials, error) {
+			calls++
+			if account != "123456789012" || region != "eu-west-1" {
+				T.Errorf("Unexpected registry %s %s", account, region)
+			}
+			return &Credentials{Host: testHost, Username: "AWS", Password: "secret", ExpiresAt: now.Add(12 * time.Hour)}, nil
+		},
+		Now: func() time.Time { return now },
+	}
+	get := func() helperCredentials {
+		var out bytes.Buffer
+		if err := h.Run("get", strings.NewReader("https://"+testHost+"\n"), &out); err != nil {
+			T.Fatal(err)
+		}
+		var creds helperCredentials
+		if err := json.Unmarshal(out.Bytes(), &creds); err != nil {
+			T.Fatal(err)
+		}
+		return creds
+	}
+
+	creds := get()
+	if creds.ServerURL != "https://"+testHost || creds.Username != "AWS" || creds.Secret != "secret" {
+		T.Errorf("Unexpected credentials %+v", creds)
+	}
+	get()
+	if calls != 1 {
+		T.Errorf("Expected cached credentials to be reused, authorized %d times", calls)
+	}
+	now = now.Add(12*time.Hour - time.Minute)
+	get()
+	if calls != 2 {
+		T.Errorf("Expected credentials about to expire to be renewed, authorized %d times", calls)
+	}
+
+	var out bytes.Buffer
+	if err := h.Run("list", nil, &out); err != nil || strings.TrimSpace(out.String()) != `{"`+testHost+`":"AWS"}` {
+		T.Errorf("Unexpected list %s %v", out.String(), err)
+	}
+	if err := h.Run("erase", strings.NewReader(testHost), &out); err != nil {
+		T.Fatal(err)
+	}
+	get()
+	if calls != 3 {
+		T.Errorf("Expected erased credentials to be renewed, authorized %d times", calls)
+	}
+
+	if err := h.Run("get", strings.NewReader("quay.io"), &out); err != errCredentialsNotFound {
+		T.Errorf("Expected other registries not to be found, got %v", err)
+	}
+	if err := h.Run("store", strings.NewReader(`{"ServerURL": "quay.io"}`), &out); err != nil {
+		T.Errorf("Expected store to be ignored, got %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "cache", "*")); len(files) != 1 {
+		T.Errorf("Expected only the cache to be left behind, found %v", files)
+	}
+
+	// A cache that cannot be written is only a cache miss
+	blocked := filepath.Join(dir, "blocked")
+	if err := ioutil.WriteFile(blocked, nil, 0600); err != nil {
+		T.Fatal(err)
+	}
+	h.Cache = filepath.Join(blocked, "credentials.json")
+	if creds := get(); creds.Secret != "secret" {
+		T.Errorf("Expected credentials even if they cannot be cached, got %+v", creds)
+	}
+}
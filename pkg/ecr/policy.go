@@ -0,0 +1,306 @@
+package ecr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// pullActions are granted to clusters so that they can pull images
+var pullActions = []string{
+	"ecr:GetDownloadUrlForLayer",
+	"ecr:BatchGetImage",
+	"ecr:BatchCheckLayerAvailability",
+	"ecr:DescribeImages",
+}
+
+var sidUnsafe = regexp.MustCompile("[^A-Za-z0-9 .-]+")
+
+// legacySid is the Sid of the statement older versions of k8ecr wrote for
+// whichever cluster was current when the repository was created
+const legacySid = "Cluster access"
+
+// clusterSid is the Sid of the statement k8ecr manages for a cluster
+func clusterSid(cluster string) string {
+	return sidUnsafe.ReplaceAllString("k8ecr "+cluster, "-")
+}
+
+// checkPrincipals refuses to write policies that grant access to nobody
+func checkPrincipals(principals []string) error {
+	if len(principals) == 0 {
+		return errors.New("No principals to grant access to")
+	}
+	for _, p := range principals {
+		if p == "" {
+			return errors.New("Refusing to grant access to an empty principal")
+		}
+	}
+	return nil
+}
+
+// clusterStatement grants the principals of a cluster read access
+func clusterStatement(cluster string, principals []string) StatementEntry {
+	return StatementEntry{
+		Sid:    clusterSid(cluster),
+		Effect: "Allow",
+		Principal: PrincipalEntry{
+			AWS: principals,
+		},
+		Action: pullActions,
+	}
+}
+
+// rawPolicy keeps every field of a policy we do not manage as it was
+type rawPolicy struct {
+	fields     map[string]json.RawMessage
+	statements []json.RawMessage
+}
+
+func parsePolicy(text string) (*rawPolicy, error) {
+	p := &rawPolicy{
+		fields:     map[string]json.RawMessage{"Version": json.RawMessage(`"2008-10-17"`)},
+		statements: make([]json.RawMessage, 0),
+	}
+	if text == "" {
+		return p, nil
+	}
+	if err := json.Unmarshal([]byte(text), &p.fields); err != nil {
+		return nil, err
+	}
+	statement := bytes.TrimSpace(p.fields["Statement"])
+	if len(statement) > 0 && statement[0] == '{' {
+		// A single statement need not be in a list
+		p.statements = append(p.statements, statement)
+	} else if len(statement) > 0 {
+		if err := json.Unmarshal(statement, &p.statements); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *rawPolicy) sid(i int) string {
+	s := struct{ Sid string }{}
+	json.Unmarshal(p.statements[i], &s)
+	return s.Sid
+}
+
+// remove the statement with the Sid, returning whether it was found
+func (p *rawPolicy) remove(sid string) bool {
+	for i := range p.statements {
+		if p.sid(i) == sid {
+			p.statements = append(p.statements[:i], p.statements[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// stringList is a policy value that may be a single string or a list
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*l = []string{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*l = many
+	return nil
+}
+
+// policyStatement is a statement as ECR returns it, which may abbreviate
+// single values and use "*" for any principal
+type policyStatement struct {
+	Sid       string
+	Effect    string
+	Principal json.RawMessage
+	Action    stringList
+}
+
+// principals returns the AWS principals of the statement, with "*" for anyone
+func (s policyStatement) principals() []string {
+	var any string
+	if err := json.Unmarshal(s.Principal, &any); err == nil {
+		return []string{any}
+	}
+	p := struct{ AWS stringList }{}
+	json.Unmarshal(s.Principal, &p)
+	return p.AWS
+}
+
+// find returns the statement with the Sid, or nil
+func (p *rawPolicy) find(sid string) *policyStatement {
+	for i := range p.statements {
+		s := &policyStatement{}
+		if err := json.Unmarshal(p.statements[i], s); err == nil && s.Sid == sid {
+			return s
+		}
+	}
+	return nil
+}
+
+func (p *rawPolicy) String() (string, error) {
+	b, err := json.Marshal(p.statements)
+	if err != nil {
+		return "", err
+	}
+	p.fields["Statement"] = b
+	b, err = json.Marshal(p.fields)
+	return string(b), err
+}
+
+// merge adds the statement to the policy, replacing any statement with the
+// same Sid and keeping all others
+func (p *rawPolicy) merge(statement StatementEntry) error {
+	b, err := json.Marshal(&statement)
+	if err != nil {
+		return err
+	}
+	for i := range p.statements {
+		if p.sid(i) == statement.Sid {
+			p.statements[i] = b
+			return nil
+		}
+	}
+	p.statements = append(p.statements, b)
+	return nil
+}
+
+// mergeStatement adds the statement to the policy text
+func mergeStatement(text string, statement StatementEntry) (string, error) {
+	p, err := parsePolicy(text)
+	if err != nil {
+		return "", err
+	}
+	if err := p.merge(statement); err != nil {
+		return "", err
+	}
+	return p.String()
+}
+
+// removeLegacy takes the principals out of the legacy cluster statement,
+// which is removed once it has none left, returning whether any were there.
+// The statement does not say which cluster it was for, so a cluster's
+// principals are all we can go by.
+func (p *rawPolicy) removeLegacy(principals []string) (bool, error) {
+	legacy := p.find(legacySid)
+	if legacy == nil {
+		return false, nil
+	}
+	remove := make(map[string]bool)
+	for _, principal := range principals {
+		remove[principal] = true
+	}
+	remaining := make([]string, 0)
+	for _, principal := range legacy.principals() {
+		if !remove[principal] {
+			remaining = append(remaining, principal)
+		}
+	}
+	if len(remaining) == len(legacy.principals()) {
+		return false, nil
+	}
+	p.remove(legacySid)
+	if len(remaining) == 0 {
+		return true, nil
+	}
+	return true, p.merge(StatementEntry{
+		Sid:       legacySid,
+		Effect:    legacy.Effect,
+		Principal: PrincipalEntry{AWS: remaining},
+		Action:    legacy.Action,
+	})
+}
+
+// getPolicy returns the repository policy text, or "" if there is none
+func (r *Registry) getPolicy(name string) (string, error) {
+	response, err := r.service.GetRepositoryPolicy(&ecr.GetRepositoryPolicyInput{
+		RepositoryName: aws.String(name),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeRepositoryPolicyNotFoundException {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(response.PolicyText), nil
+}
+
+// Grant gives the principals of the cluster read access to the repository,
+// keeping any other statements in its policy. The principals are moved out
+// of the legacy cluster statement into the cluster's own.
+func (r *Registry) Grant(name, cluster string, principals []string) error {
+	if err := checkPrincipals(principals); err != nil {
+		return err
+	}
+	current, err := r.getPolicy(name)
+	if err != nil {
+		return err
+	}
+	p, err := parsePolicy(current)
+	if err != nil {
+		return err
+	}
+	if _, err := p.removeLegacy(principals); err != nil {
+		return err
+	}
+	if err := p.merge(clusterStatement(cluster, principals)); err != nil {
+		return err
+	}
+	policy, err := p.String()
+	if err != nil {
+		return err
+	}
+	_, err = r.service.SetRepositoryPolicy(&ecr.SetRepositoryPolicyInput{
+		RepositoryName: aws.String(name),
+		PolicyText:     aws.String(policy),
+	})
+	return err
+}
+
+// Revoke removes the cluster's access to the repository, including that of
+// its principals through the legacy cluster statement, returning whether it
+// had been granted
+func (r *Registry) Revoke(name, cluster string, principals []string) (bool, error) {
+	current, err := r.getPolicy(name)
+	if err != nil {
+		return false, err
+	}
+	p, err := parsePolicy(current)
+	if err != nil {
+		return false, err
+	}
+	removed := p.remove(clusterSid(cluster))
+	legacy, err := p.removeLegacy(principals)
+	if err != nil {
+		return false, err
+	}
+	if !removed && !legacy {
+		return false, nil
+	}
+	if len(p.statements) == 0 {
+		_, err = r.service.DeleteRepositoryPolicy(&ecr.DeleteRepositoryPolicyInput{
+			RepositoryName: aws.String(name),
+		})
+		return true, err
+	}
+	policy, err := p.String()
+	if err != nil {
+		return false, err
+	}
+	_, err = r.service.SetRepositoryPolicy(&ecr.SetRepositoryPolicyInput{
+		RepositoryName: aws.String(name),
+		PolicyText:     aws.String(policy),
+	})
+	return true, err
+}
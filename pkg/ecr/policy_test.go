@@ -0,0 +1,104 @@
+package ecr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const existingPolicy = `{
+  "Version": "2008-10-17",
+  "Statement": [
+    {
+      "Sid": "CI push",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::123456789012:role/ci"},
+      "Action": ["ecr:PutImage"],
+      "Condition": {"StringEquals": {"aws:SourceVpc": "vpc-1"}}
+    },
+    {
+      "Sid": "k8ecr prod.example.com",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::123456789012:role/old"]},
+      "Action": ["ecr:BatchGetImage"]
+    }
+  ]
+}`
+
+func statements(T *testing.T, text string) []map[string]interface{} {
+	policy := struct{ Statement []map[string]interface{} }{}
+	if err := json.Unmarshal([]byte(text), &policy); err != nil {
+		T.Fatal(err)
+	}
+	return policy.Statement
+}
+
+func TestMergeStatement(T *testing.T) {
+	statement := clusterStatement("prod.example.com", []string{"arn:aws:iam::123456789012:role/nodes"})
+	merged, err := mergeStatement(existingPolicy, statement)
+	if err != nil {
+		T.Fatal(err)
+	}
+	s := statements(T, merged)
+	if len(s) != 2 {
+		T.Fatalf("Expected the cluster statement to be replaced, got %v", s)
+	}
+	if s[0]["Condition"] == nil || s[0]["Sid"] != "CI push" {
+		T.Errorf("Other statements should be kept as they were: %v", s[0])
+	}
+	if len(s[1]["Action"].([]interface{})) != 4 {
+		T.Errorf("Cluster statement was not updated: %v", s[1])
+	}
+	merged, err = mergeStatement(existingPolicy, clusterStatement("staging.example.com", []string{"arn"}))
+	if err != nil || len(statements(T, merged)) != 3 {
+		T.Errorf("Expected a statement to be added for another cluster: %s %v", merged, err)
+	}
+	merged, err = mergeStatement("", statement)
+	if err != nil || len(statements(T, merged)) != 1 {
+		T.Errorf("Expected a new policy: %s %v", merged, err)
+	}
+}
+
+func TestRemoveStatement(T *testing.T) {
+	p, err := parsePolicy(existingPolicy)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if p.remove(clusterSid("staging.example.com")) {
+		T.Errorf("Removed a statement for a cluster that had none")
+	}
+	if !p.remove(clusterSid("prod.example.com")) || len(p.statements) != 1 {
+		T.Errorf("Failed to remove the cluster statement")
+	}
+	text, err := p.String()
+	if err != nil || statements(T, text)[0]["Sid"] != "CI push" {
+		T.Errorf("Wrong statement removed: %s %v", text, err)
+	}
+}
+
+func TestClusterSid(T *testing.T) {
+	sid := clusterSid("arn:aws:eks:eu-west-2:123456789012:cluster/prod")
+	if sid != "k8ecr arn-aws-eks-eu-west-2-123456789012-cluster-prod" {
+		T.Errorf("clusterSid is wrong: %s", sid)
+	}
+}
+
+func TestRemoveLegacy(T *testing.T) {
+	legacy := `{"Statement": [{"Sid": "Cluster access", "Effect": "Allow", "Principal": {"AWS": ["a", "b"]}, "Action": ["ecr:BatchGetImage"]}]}`
+	p, err := parsePolicy(legacy)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if removed, err := p.removeLegacy([]string{"c"}); removed || err != nil {
+		T.Errorf("Removed principals the legacy statement did not have")
+	}
+	if removed, err := p.removeLegacy([]string{"a"}); !removed || err != nil {
+		T.Errorf("Failed to remove a principal from the legacy statement")
+	}
+	s := p.find(legacySid)
+	if s == nil || len(s.principals()) != 1 || s.principals()[0] != "b" || s.Action[0] != "ecr:BatchGetImage" {
+		T.Errorf("Legacy statement should keep its other principals: %v", s)
+	}
+	if removed, _ := p.removeLegacy([]string{"b"}); !removed || len(p.statements) != 0 {
+		T.Errorf("Legacy statement should be removed once it has no principals")
+	}
+}
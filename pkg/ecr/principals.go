@@ -0,0 +1,68 @@
+package ecr
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+func getClusterRole(cluster string, role string) (string, error) {
+	svc := iam.New(createSession())
+	name := fmt.Sprintf("%s.%s", role, cluster)
+	input := &iam.GetRoleInput{
+		RoleName: &name,
+	}
+	result, err := svc.GetRole(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == iam.ErrCodeNoSuchEntityException {
+			return "", fmt.Errorf("No IAM role %s found for cluster %s", name, cluster)
+		}
+		return "", err
+	}
+	return *result.Role.Arn, nil
+}
+
+// KopsRoles returns the roles of the masters and nodes of a kops cluster
+func KopsRoles(cluster string) ([]string, error) {
+	principals := make([]string, 0)
+	for _, role := range []string{"masters", "nodes"} {
+		arn, err := getClusterRole(cluster, role)
+		if err != nil {
+			return nil, err
+		}
+		principals = append(principals, arn)
+	}
+	return principals, nil
+}
+
+// NodegroupRoles returns the node roles of the managed node groups of an EKS cluster
+func NodegroupRoles(cluster string) ([]string, error) {
+	svc := eks.New(createSession())
+	names := make([]*string, 0)
+	err := svc.ListNodegroupsPages(&eks.ListNodegroupsInput{
+		ClusterName: aws.String(cluster),
+	}, func(page *eks.ListNodegroupsOutput, lastPage bool) bool {
+		names = append(names, page.Nodegroups...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]string, 0)
+	for _, name := range names {
+		response, err := svc.DescribeNodegroup(&eks.DescribeNodegroupInput{
+			ClusterName:   aws.String(cluster),
+			NodegroupName: name,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if response.Nodegroup.NodeRole != nil {
+			roles = append(roles, *response.Nodegroup.NodeRole)
+		}
+	}
+	return roles, nil
+}
@@ -0,0 +1,160 @@
+package ecr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Progress modes
+const (
+	ProgressTTY   = "tty"
+	ProgressPlain = "plain"
+	ProgressJSON  = "json"
+)
+
+// ProgressAux is the result the daemon reports at the end of a push
+type ProgressAux struct {
+	Tag    string
+	Digest string
+	Size   int
+}
+
+// ProgressLine is an event in a push. Lines without an ID are about the
+// whole image rather than a layer.
+type ProgressLine struct {
+	ID             string
+	Status         string
+	Progress       string
+	ProgressDetail map[string]int
+	Error          string
+	Aux            *ProgressAux
+}
+
+// Progress displays the events of pushes, which may be concurrent
+type Progress interface {
+	Update(ref string, data *ProgressLine)
+}
+
+// NewProgress returns a progress display for the mode. tty redraws a line
+// per layer, plain writes a line whenever a layer's status changes, and json
+// writes those changes as JSON objects.
+func NewProgress(mode string, out io.Writer) Progress {
+	switch mode {
+	case ProgressPlain:
+		return &eventProgress{out: out, last: make(map[string]string)}
+	case ProgressJSON:
+		return &eventProgress{out: out, last: make(map[string]string), json: json.NewEncoder(out)}
+	}
+	return &ttyProgress{display: ProgressDisplay{Bars: make(map[string]int)}}
+}
+
+func shortDigest(digest string) string {
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+	return digest
+}
+
+func formatProgress(data *ProgressLine) string {
+	progress := data.Progress
+	if progress == "" {
+		progress = data.Status
+	}
+	return fmt.Sprintf("%s: %s", data.ID, progress)
+}
+
+// ProgressDisplay redraws a line per layer using ANSI escapes
+type ProgressDisplay struct {
+	Bars  map[string]int
+	Lines int
+}
+
+func updateLine(lineno int, message string) {
+	escape := "\x1b"
+	fmt.Printf("%s[1000D", escape)       // Move left
+	fmt.Printf("%s[%dA", escape, lineno) // Move up
+	fmt.Printf("%s", message)
+	fmt.Printf("%s[%dB", escape, lineno) // Move down
+}
+
+// Update the progress and print the output
+func (p *ProgressDisplay) Update(data *ProgressLine) {
+	if data.ID != "" {
+		line, ok := p.Bars[data.ID]
+		if ok {
+			updateLine(p.Lines-line, formatProgress(data))
+		} else {
+			p.Bars[data.ID] = p.Lines
+			p.Lines++
+			fmt.Println(data.ID)
+		}
+	} else if data.Status != "" {
+		p.Lines++
+		fmt.Println(data.Status)
+	}
+}
+
+type ttyProgress struct {
+	mu      sync.Mutex
+	display ProgressDisplay
+}
+
+func (p *ttyProgress) Update(ref string, data *ProgressLine) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	line := *data
+	if line.ID != "" {
+		// Layers shared between concurrent pushes still get a line each
+		line.ID = ref + " " + line.ID
+	}
+	p.display.Update(&line)
+}
+
+// progressEvent is the JSON written for each event
+type progressEvent struct {
+	Reference string `json:"reference"`
+	Layer     string `json:"layer,omitempty"`
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+}
+
+type eventProgress struct {
+	mu   sync.Mutex
+	out  io.Writer
+	json *json.Encoder
+	last map[string]string
+}
+
+func (p *eventProgress) Update(ref string, data *ProgressLine) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	event := progressEvent{Reference: ref, Layer: data.ID, Status: data.Status}
+	if data.Aux != nil {
+		event.Status = "Pushed"
+		event.Digest = data.Aux.Digest
+	}
+	if event.Status == "" {
+		return
+	}
+	// Progress bars are not events, only changes of status are
+	key := ref + " " + data.ID
+	if data.ID != "" && p.last[key] == event.Status {
+		return
+	}
+	p.last[key] = event.Status
+	if p.json != nil {
+		p.json.Encode(&event)
+		return
+	}
+	if event.Layer != "" {
+		fmt.Fprintf(p.out, "%s %s: %s\n", ref, event.Layer, event.Status)
+	} else if event.Digest != "" {
+		fmt.Fprintf(p.out, "%s: %s %s\n", ref, event.Status, event.Digest)
+	} else {
+		fmt.Fprintf(p.out, "%s: %s\n", ref, event.Status)
+	}
+}
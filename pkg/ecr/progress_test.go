@@ -0,0 +1,52 @@
+package ecr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var pushEvents = []ProgressLine{
+	{Status: "The push refers to repository [ecr/app]"},
+	{ID: "abc", Status: "Preparing"},
+	{ID: "abc", Status: "Pushing", Progress: "[=>   ] 1MB/10MB"},
+	{ID: "abc", Status: "Pushing", Progress: "[===> ] 8MB/10MB"},
+	{ID: "abc", Status: "Pushed"},
+	{Aux: &ProgressAux{Tag: "1.0.0", Digest: "sha256:def"}},
+}
+
+func TestPlainProgress(T *testing.T) {
+	out := &bytes.Buffer{}
+	progress := NewProgress(ProgressPlain, out)
+	for i := range pushEvents {
+		progress.Update("app:1.0.0", &pushEvents[i])
+	}
+	expected := []string{
+		"app:1.0.0: The push refers to repository [ecr/app]",
+		"app:1.0.0 abc: Preparing",
+		"app:1.0.0 abc: Pushing",
+		"app:1.0.0 abc: Pushed",
+		"app:1.0.0: Pushed sha256:def",
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		T.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
+
+func TestJSONProgress(T *testing.T) {
+	out := &bytes.Buffer{}
+	progress := NewProgress(ProgressJSON, out)
+	for i := range pushEvents {
+		progress.Update("app:1.0.0", &pushEvents[i])
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		T.Fatalf("Expected 5 events, got:\n%s", out.String())
+	}
+	if lines[1] != `{"reference":"app:1.0.0","layer":"abc","status":"Preparing"}` {
+		T.Errorf("Unexpected event %s", lines[1])
+	}
+	if lines[4] != `{"reference":"app:1.0.0","status":"Pushed","digest":"sha256:def"}` {
+		T.Errorf("Unexpected event %s", lines[4])
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
+	"sync"
 
 	"encoding/base64"
 	"encoding/json"
@@ -14,30 +16,22 @@ import (
 	docker "docker.io/go-docker"
 	"docker.io/go-docker/api/types"
 	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/isotoma/k8ecr/pkg/registry"
 )
 
-type PushCommand struct{}
-
-var pushCommand PushCommand
-
 func getCredentials() (types.AuthConfig, error) {
-	svc := ecr.New(createSession())
-	response, err := svc.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
-	if err != nil {
-		return types.AuthConfig{}, err
-	}
-	token, err := base64.StdEncoding.DecodeString(*response.AuthorizationData[0].AuthorizationToken)
+	return credentials(ecr.New(createSession()), &ecr.GetAuthorizationTokenInput{})
+}
+
+func credentials(svc *ecr.ECR, input *ecr.GetAuthorizationTokenInput) (types.AuthConfig, error) {
+	creds, err := authorization(svc, input)
 	if err != nil {
 		return types.AuthConfig{}, err
 	}
-	parts := strings.Split(string(token), ":")
-	username := parts[0]
-	password := parts[1]
-	endpoint := *response.AuthorizationData[0].ProxyEndpoint
 	return types.AuthConfig{
-		Username:      username,
-		Password:      password,
-		ServerAddress: endpoint[8:], // strip the https://
+		Username:      creds[0].Username,
+		Password:      creds[0].Password,
+		ServerAddress: creds[0].Host,
 	}, nil
 }
 
@@ -50,12 +44,11 @@ func login() (*docker.Client, types.AuthConfig, error) {
 	if err != nil {
 		return cli, creds, err
 	}
-	fmt.Println("Logging into", creds.ServerAddress)
-	response, err := cli.RegistryLogin(context.Background(), creds)
+	fmt.Fprintln(os.Stderr, "Logging into", creds.ServerAddress)
+	_, err = cli.RegistryLogin(context.Background(), creds)
 	if err != nil {
 		return nil, creds, err
 	}
-	fmt.Println(response)
 	return cli, creds, nil
 }
 
@@ -69,20 +62,20 @@ func registryAuth(creds types.AuthConfig) string {
 	return base64.StdEncoding.EncodeToString(b)
 }
 
-func tag(client *docker.Client, endpoint string, repo string, version string) error {
-	source := fmt.Sprintf("%s:%s", repo, version)
+func tag(client *docker.Client, source string, endpoint string, repo string, version string) error {
+	if source == "" {
+		source = fmt.Sprintf("%s:%s", repo, version)
+	}
 	target := fmt.Sprintf("%s/%s:%s", endpoint, repo, version)
-	fmt.Println("Tagging", source, target)
 	return client.ImageTag(context.Background(), source, target)
 }
 
-func startPush(client *docker.Client, creds types.AuthConfig, repo string, version string) (io.ReadCloser, error) {
-	err := tag(client, creds.ServerAddress, repo, version)
+func startPush(client *docker.Client, creds types.AuthConfig, source string, repo string, version string) (io.ReadCloser, error) {
+	err := tag(client, source, creds.ServerAddress, repo, version)
 	if err != nil {
 		return nil, err
 	}
 	image := fmt.Sprintf("%s/%s:%s", creds.ServerAddress, repo, version)
-	fmt.Println("\n\nPushing ", image)
 	stream, err := client.ImagePush(context.Background(),
 		image,
 		types.ImagePushOptions{
@@ -111,91 +104,191 @@ func getNextLine(stream *bufio.Reader) (*ProgressLine, error) {
 	return &data, nil
 }
 
-func formatProgress(data *ProgressLine) string {
-	progress := data.Progress
-	if progress == "" {
-		progress = data.Status
+// push the image, returning the digest the registry reports for it
+func push(client *docker.Client, creds types.AuthConfig, source string, repo string, version string, progress Progress) (string, error) {
+	ref := fmt.Sprintf("%s:%s", repo, version)
+	progress.Update(ref, &ProgressLine{Status: "Pushing " + ref})
+	rawStream, err := startPush(client, creds, source, repo, version)
+	if err != nil {
+		return "", err
+	}
+	defer rawStream.Close()
+	stream := bufio.NewReader(rawStream)
+	digest := ""
+	for {
+		data, err := getNextLine(stream)
+		if err == io.EOF {
+			if digest == "" {
+				return "", fmt.Errorf("Push of %s finished without a digest", ref)
+			}
+			return digest, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if data.Aux != nil && data.Aux.Digest != "" {
+			digest = data.Aux.Digest
+		}
+		progress.Update(ref, data)
 	}
-	return fmt.Sprintf("%s: %s", data.ID, progress)
-}
-
-type ProgressLine struct {
-	ID             string
-	Status         string
-	Progress       string
-	ProgressDetail map[string]int
-	Error          string
 }
 
-type ProgressDisplay struct {
-	Bars  map[string]int
-	Lines int
+// PushTarget is a local image to push to the ECR repository of the same name
+type PushTarget struct {
+	Repo   string
+	Tag    string
+	Source string // Local image to push, if not REPO:TAG
 }
 
-func updateLine(lineno int, message string) {
-	escape := "\x1b"
-	fmt.Printf("%s[1000D", escape)       // Move left
-	fmt.Printf("%s[%dA", escape, lineno) // Move up
-	fmt.Printf("%s", message)
-	fmt.Printf("%s[%dB", escape, lineno) // Move down
+// PushResult is the digest a pushed reference resolved to, or why it failed
+type PushResult struct {
+	Reference string `json:"reference"`
+	Digest    string `json:"digest,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
-// Update the progress and print the output
-func (p *ProgressDisplay) Update(data *ProgressLine) {
-	if data.ID != "" {
-		line, ok := p.Bars[data.ID]
-		if ok {
-			updateLine(p.Lines-line, formatProgress(data))
-		} else {
-			p.Bars[data.ID] = p.Lines
-			p.Lines++
-			fmt.Println(data.ID)
-		}
+// pushTarget pushes the image, or only tags it if the repository already
+// has it, returning its digest
+func (r *Registry) pushTarget(cli *docker.Client, creds types.AuthConfig, t PushTarget, images *repositoryImages, progress Progress) (string, error) {
+	ref := fmt.Sprintf("%s:%s", t.Repo, t.Tag)
+	source := t.Source
+	if source == "" {
+		source = ref
+	}
+	digest, err := localDigest(cli, source, creds.ServerAddress+"/"+t.Repo)
+	if err != nil {
+		return "", err
 	}
+	action, err := images.plan(t.Tag, digest)
+	if err != nil {
+		return "", err
+	}
+	switch action {
+	case pushSkip:
+		progress.Update(ref, &ProgressLine{Status: "Already tagged " + digest})
+		return digest, nil
+	case pushTag:
+		progress.Update(ref, &ProgressLine{Status: "Already pushed as " + digest + ", tagging"})
+		return digest, r.TagDigest(t.Repo, digest, t.Tag)
+	}
+	return push(cli, creds, t.Source, t.Repo, t.Tag, progress)
 }
 
-func push(client *docker.Client, creds types.AuthConfig, repo string, version string) error {
-	rawStream, err := startPush(client, creds, repo, version)
+// PushImages pushes the images through the Docker daemon, up to jobs at a
+// time, and returns the result of each in order
+func (r *Registry) PushImages(targets []PushTarget, jobs int, progress Progress) ([]PushResult, error) {
+	cli, creds, err := login()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	stream := bufio.NewReader(rawStream)
-	display := ProgressDisplay{
-		Bars:  make(map[string]int),
-		Lines: 0,
+	if jobs < 1 {
+		jobs = 1
 	}
-	for {
-		data, err := getNextLine(stream)
-		if err != io.EOF && err != nil {
-			rawStream.Close()
-			return err
-		}
-		if data != nil {
-			if data.Error != "" {
-				fmt.Println("\n\n", data.Error)
-				return errors.New(data.Error)
+	repositories := make(map[string]*repositoryImages)
+	for _, t := range targets {
+		if _, ok := repositories[t.Repo]; !ok {
+			if repositories[t.Repo], err = r.repositoryImages(t.Repo); err != nil {
+				return nil, err
 			}
-			display.Update(data)
-		}
-		if err == io.EOF {
-			fmt.Println("\n\nDone")
-			rawStream.Close()
-			return nil
 		}
 	}
+	results := make([]PushResult, len(targets))
+	slots := make(chan bool, jobs)
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		slots <- true
+		go func(i int, t PushTarget) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i].Reference = fmt.Sprintf("%s/%s:%s", creds.ServerAddress, t.Repo, t.Tag)
+			digest, err := r.pushTarget(cli, creds, t, repositories[t.Repo], progress)
+			if err != nil {
+				results[i].Error = err.Error()
+				progress.Update(t.Repo+":"+t.Tag, &ProgressLine{Status: "Failed: " + err.Error()})
+				return
+			}
+			results[i].Digest = digest
+		}(i, t)
+	}
+	wg.Wait()
+	return results, nil
 }
 
 // PushRepository pushes
 func (r *Registry) PushRepository(name string, versions []string) error {
-	cli, creds, err := login()
+	targets := make([]PushTarget, len(versions))
+	for i, v := range versions {
+		targets[i] = PushTarget{Repo: name, Tag: v}
+	}
+	results, err := r.PushImages(targets, 1, NewProgress(ProgressTTY, os.Stdout))
 	if err != nil {
 		return err
 	}
-	for _, v := range versions {
-		err := push(cli, creds, name, v)
-		if err != nil {
-			return err
+	for _, result := range results {
+		if result.Error != "" {
+			return errors.New(result.Error)
 		}
 	}
 	return nil
 }
+
+// Client returns a client for the registry API, authenticated with an ECR token
+func (r *Registry) Client() (*registry.Client, error) {
+	return ClientFor("", "")
+}
+
+// PushArchive pushes an image read from an OCI layout or docker archive
+// straight to the registry, without a Docker daemon
+func (r *Registry) PushArchive(name string, source string, versions []string, progress Progress) ([]PushResult, error) {
+	img, err := registry.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	client, err := r.Client()
+	if err != nil {
+		return nil, err
+	}
+	ref := fmt.Sprintf("%s:%s", name, strings.Join(versions, ","))
+	client.Progress = func(digest, status string) {
+		progress.Update(ref, &ProgressLine{ID: shortDigest(digest), Status: status})
+	}
+	images, err := r.repositoryImages(name)
+	if err != nil {
+		return nil, err
+	}
+	digest := img.Manifest.Digest
+	results := make([]PushResult, len(versions))
+	uploads := make([]string, 0)
+	for i, v := range versions {
+		results[i] = PushResult{Reference: fmt.Sprintf("%s/%s:%s", client.Host, name, v), Digest: digest}
+		action, err := images.plan(v, digest)
+		switch {
+		case err != nil:
+			results[i] = PushResult{Reference: results[i].Reference, Error: err.Error()}
+		case action == pushSkip:
+			progress.Update(ref, &ProgressLine{Status: "Already tagged " + v})
+		case action == pushTag:
+			progress.Update(ref, &ProgressLine{Status: "Already pushed, tagging " + v})
+			if err := client.PutManifest(name, v, &img.Manifest); err != nil {
+				results[i] = PushResult{Reference: results[i].Reference, Error: err.Error()}
+			}
+		default:
+			uploads = append(uploads, v)
+		}
+	}
+	if len(uploads) > 0 {
+		progress.Update(ref, &ProgressLine{Status: "Pushing " + source})
+		if _, err := client.PushImage(name, img, uploads); err != nil {
+			for i, v := range versions {
+				for _, u := range uploads {
+					if u == v {
+						results[i] = PushResult{Reference: results[i].Reference, Error: err.Error()}
+					}
+				}
+			}
+		}
+	}
+	return results, nil
+}
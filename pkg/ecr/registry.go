@@ -12,6 +12,7 @@ type Repository struct {
 	Name      string
 	LatestTag string
 	Tags      []string
+	Digests   map[string]string // Map of tags to image digests
 }
 
 // Registry represents your an ECR in a region
@@ -35,13 +36,14 @@ func (r *Registry) FetchAll() error {
 		return err
 	}
 	for _, repo := range repositories {
-		tags, err := getTagsForRepository(r.service, repo.Name)
+		tags, digests, err := getTagsForRepository(r.service, repo.Name)
 		if err != nil {
 			return err
 		}
 		latest := latestVersion(tags)
 		repo.LatestTag = latest
 		repo.Tags = tags
+		repo.Digests = digests
 		r.Repositories[repo.Name] = repo
 	}
 	return nil
@@ -20,7 +20,7 @@ func getAllRepositories(svc *ecr.ECR) ([]Repository, error) {
 	return repositories, nil
 }
 
-func getTagsForRepositoryPage(svc *ecr.ECR, repository string, tagList []string, nextToken *string) ([]string, *string, error) {
+func getTagsForRepositoryPage(svc *ecr.ECR, repository string, tagList []string, digests map[string]string, nextToken *string) ([]string, *string, error) {
 	response, err := svc.DescribeImages(&ecr.DescribeImagesInput{
 		RepositoryName: &repository,
 		NextToken:      nextToken,
@@ -30,6 +30,7 @@ func getTagsForRepositoryPage(svc *ecr.ECR, repository string, tagList []string,
 	}
 	for _, i := range response.ImageDetails {
 		for _, t := range i.ImageTags {
+			digests[*t] = *i.ImageDigest
 			if *t != "latest" {
 				tagList = append(tagList, *t)
 			}
@@ -38,19 +39,21 @@ func getTagsForRepositoryPage(svc *ecr.ECR, repository string, tagList []string,
 	return tagList, response.NextToken, nil
 }
 
-// GetTagsForRepository gets all the tags in a specified repository
-func getTagsForRepository(svc *ecr.ECR, repository string) ([]string, error) {
+// GetTagsForRepository gets all the tags in a specified repository,
+// and the digest of the image each tag refers to
+func getTagsForRepository(svc *ecr.ECR, repository string) ([]string, map[string]string, error) {
 	tagList := make([]string, 0)
-	tagList, nextToken, err := getTagsForRepositoryPage(svc, repository, tagList, nil)
+	digests := make(map[string]string)
+	tagList, nextToken, err := getTagsForRepositoryPage(svc, repository, tagList, digests, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for nextToken != nil {
-		tagList, nextToken, err = getTagsForRepositoryPage(svc, repository, tagList, nextToken)
+		tagList, nextToken, err = getTagsForRepositoryPage(svc, repository, tagList, digests, nextToken)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 	}
-	return tagList, nil
+	return tagList, digests, nil
 }
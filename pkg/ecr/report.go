@@ -0,0 +1,158 @@
+package ecr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ScanReport is the scan of an image, with the apps that run it
+type ScanReport struct {
+	Image string      `json:"image"` // REPOSITORY:TAG or REPOSITORY@DIGEST
+	Apps  []string    `json:"apps,omitempty"`
+	Scan  *ScanResult `json:"scan"`
+}
+
+func severityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities)
+}
+
+// SortVulnerabilities orders vulnerabilities from most to least severe, then
+// by name and package
+func SortVulnerabilities(vs []Vulnerability) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		a, b := vs[i], vs[j]
+		if ra, rb := severityRank(a.Severity), severityRank(b.Severity); ra != rb {
+			return ra < rb
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Package < b.Package
+	})
+}
+
+// SARIF is a Static Analysis Results Interchange Format 2.1.0 log, as read
+// by code scanning dashboards
+type SARIF struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID               string                 `json:"id"`
+	ShortDescription sarifText              `json:"shortDescription"`
+	FullDescription  sarifText              `json:"fullDescription"`
+	HelpURI          string                 `json:"helpUri,omitempty"`
+	Properties       map[string]interface{} `json:"properties"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifText       `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+// sarifLevels maps severities to SARIF levels
+var sarifLevels = map[string]string{
+	"CRITICAL": "error",
+	"HIGH":     "error",
+	"MEDIUM":   "warning",
+}
+
+// securitySeverity is the score code scanning ranks each severity by
+var securitySeverity = map[string]string{
+	"CRITICAL": "9.5",
+	"HIGH":     "8.0",
+	"MEDIUM":   "5.5",
+	"LOW":      "2.0",
+}
+
+// NewSARIF converts the reports to a SARIF log, with a rule per CVE and a
+// result per vulnerable package in each image
+func NewSARIF(reports []ScanReport) *SARIF {
+	driver := sarifDriver{
+		Name:           "k8ecr",
+		InformationURI: "https://github.com/isotoma/k8ecr",
+		Rules:          make([]sarifRule, 0),
+	}
+	results := make([]sarifResult, 0)
+	rules := make(map[string]bool)
+	for _, report := range reports {
+		if report.Scan == nil {
+			continue
+		}
+		for _, v := range report.Scan.Vulnerabilities {
+			if !rules[v.Name] {
+				rules[v.Name] = true
+				description := v.Description
+				if description == "" {
+					description = v.Name
+				}
+				rule := sarifRule{
+					ID:               v.Name,
+					ShortDescription: sarifText{fmt.Sprintf("%s %s", v.Severity, v.Name)},
+					FullDescription:  sarifText{description},
+					HelpURI:          v.URI,
+					Properties:       map[string]interface{}{"tags": []string{"security"}},
+				}
+				if score, ok := securitySeverity[v.Severity]; ok {
+					rule.Properties["security-severity"] = score
+				}
+				driver.Rules = append(driver.Rules, rule)
+			}
+			level, ok := sarifLevels[v.Severity]
+			if !ok {
+				level = "note"
+			}
+			message := fmt.Sprintf("%s in %s", v.Name, report.Image)
+			if v.Package != "" {
+				message = strings.TrimSpace(fmt.Sprintf("%s in %s %s", v.Name, v.Package, v.Version)) + " of " + report.Image
+			}
+			result := sarifResult{RuleID: v.Name, Level: level, Message: sarifText{message}}
+			var location sarifLocation
+			location.PhysicalLocation.ArtifactLocation.URI = report.Image
+			result.Locations = []sarifLocation{location}
+			results = append(results, result)
+		}
+	}
+	return &SARIF{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+}
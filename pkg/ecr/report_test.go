@@ -0,0 +1,54 @@
+package ecr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSortVulnerabilities(T *testing.T) {
+	vs := []Vulnerability{
+		{Name: "CVE-2", Severity: "LOW"},
+		{Name: "CVE-3", Severity: "CRITICAL"},
+		{Name: "CVE-1", Severity: "LOW"},
+		{Name: "CVE-4", Severity: "SOMETHING"},
+		{Name: "CVE-5", Severity: "HIGH"},
+	}
+	SortVulnerabilities(vs)
+	order := ""
+	for _, v := range vs {
+		order += v.Name + " "
+	}
+	if order != "CVE-3 CVE-5 CVE-1 CVE-2 CVE-4 " {
+		T.Errorf("Unexpected order %s", order)
+	}
+}
+
+func TestNewSARIF(T *testing.T) {
+	cve := Vulnerability{Name: "CVE-2019-0001", Severity: "HIGH", URI: "https://security-tracker.debian.org/tracker/CVE-2019-0001", Package: "openssl", Version: "1.1.0"}
+	reports := []ScanReport{
+		{Image: "web:1.0.0", Scan: &ScanResult{Status: ScanComplete, Vulnerabilities: []Vulnerability{cve, {Name: "CVE-2019-0002", Severity: "LOW"}}}},
+		{Image: "worker:1.0.0", Scan: &ScanResult{Status: ScanComplete, Vulnerabilities: []Vulnerability{cve}}},
+	}
+	log := NewSARIF(reports)
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || len(run.Results) != 3 {
+		T.Fatalf("Expected a rule per CVE and a result per finding, got %d rules and %d results", len(run.Tool.Driver.Rules), len(run.Results))
+	}
+	rule := run.Tool.Driver.Rules[0]
+	if rule.HelpURI != cve.URI || rule.Properties["security-severity"] != "8.0" {
+		T.Errorf("Unexpected rule %+v", rule)
+	}
+	first, last := run.Results[0], run.Results[2]
+	if first.Level != "error" || first.Message.Text != "CVE-2019-0001 in openssl 1.1.0 of web:1.0.0" {
+		T.Errorf("Unexpected result %+v", first)
+	}
+	if last.Locations[0].PhysicalLocation.ArtifactLocation.URI != "worker:1.0.0" {
+		T.Errorf("Unexpected location %+v", last.Locations)
+	}
+	if run.Results[1].Level != "note" {
+		T.Errorf("Expected LOW findings to be notes, got %s", run.Results[1].Level)
+	}
+	if _, err := json.Marshal(log); err != nil {
+		T.Error(err)
+	}
+}
@@ -0,0 +1,266 @@
+package ecr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// Scan statuses
+const (
+	ScanInProgress = "IN_PROGRESS"
+	ScanComplete   = "COMPLETE"
+	ScanFailed     = "FAILED"
+)
+
+// Severities of findings, most severe first
+var Severities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "INFORMATIONAL", "UNDEFINED"}
+
+// scanPollInterval is how often WaitForScan and WaitForScans check a scan
+const scanPollInterval = 5 * time.Second
+
+// Vulnerability is a finding of an image scan
+type Vulnerability struct {
+	Name        string `json:"name"`
+	Severity    string `json:"severity"`
+	URI         string `json:"uri,omitempty"`
+	Description string `json:"description,omitempty"`
+	Package     string `json:"package,omitempty"`
+	Version     string `json:"version,omitempty"`
+}
+
+// ScanResult is the outcome of the most recent scan of an image
+type ScanResult struct {
+	Repository      string          `json:"repository"`
+	Digest          string          `json:"digest"`
+	Status          string          `json:"status"`
+	Description     string          `json:"description,omitempty"`
+	CompletedAt     time.Time       `json:"completedAt"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+// Counts returns the number of vulnerabilities of each severity, leaving
+// out those ignored
+func (s *ScanResult) Counts(ignore map[string]bool) map[string]int {
+	counts := make(map[string]int)
+	for _, v := range s.Vulnerabilities {
+		if !ignore[v.Name] {
+			counts[v.Severity]++
+		}
+	}
+	return counts
+}
+
+// imageID identifies an image by digest or tag
+func imageID(reference string) *ecr.ImageIdentifier {
+	if strings.HasPrefix(reference, "sha256:") {
+		return &ecr.ImageIdentifier{ImageDigest: aws.String(reference)}
+	}
+	return &ecr.ImageIdentifier{ImageTag: aws.String(reference)}
+}
+
+// ScanFindings returns the most recent scan of the image, or nil if it has
+// never been scanned
+func (r *Registry) ScanFindings(name, reference string) (*ScanResult, error) {
+	var result *ScanResult
+	err := r.service.DescribeImageScanFindingsPages(&ecr.DescribeImageScanFindingsInput{
+		RepositoryName: aws.String(name),
+		ImageId:        imageID(reference),
+		MaxResults:     aws.Int64(1000),
+	}, func(page *ecr.DescribeImageScanFindingsOutput, lastPage bool) bool {
+		if result == nil {
+			result = &ScanResult{
+				Repository:      name,
+				Digest:          aws.StringValue(page.ImageId.ImageDigest),
+				Vulnerabilities: make([]Vulnerability, 0),
+			}
+			if page.ImageScanStatus != nil {
+				result.Status = aws.StringValue(page.ImageScanStatus.Status)
+				result.Description = aws.StringValue(page.ImageScanStatus.Description)
+			}
+		}
+		if page.ImageScanFindings == nil {
+			return true
+		}
+		result.CompletedAt = aws.TimeValue(page.ImageScanFindings.ImageScanCompletedAt)
+		for _, f := range page.ImageScanFindings.Findings {
+			v := Vulnerability{
+				Name:        aws.StringValue(f.Name),
+				Severity:    aws.StringValue(f.Severity),
+				URI:         aws.StringValue(f.Uri),
+				Description: aws.StringValue(f.Description),
+			}
+			for _, a := range f.Attributes {
+				switch aws.StringValue(a.Key) {
+				case "package_name":
+					v.Package = aws.StringValue(a.Value)
+				case "package_version":
+					v.Version = aws.StringValue(a.Value)
+				}
+			}
+			result.Vulnerabilities = append(result.Vulnerabilities, v)
+		}
+		return true
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeScanNotFoundException {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s: %s", name, reference, err)
+	}
+	return result, nil
+}
+
+// StartScan starts a scan of the image. It returns false if the image was
+// already scanned today, as ECR scans an image at most once a day.
+func (r *Registry) StartScan(name, reference string) (bool, error) {
+	_, err := r.service.StartImageScan(&ecr.StartImageScanInput{
+		RepositoryName: aws.String(name),
+		ImageId:        imageID(reference),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeLimitExceededException {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s:%s: %s", name, reference, err)
+	}
+	return true, nil
+}
+
+// WaitForScan waits for the scan of the image to finish, returning the
+// result, or an error if it takes longer than the timeout
+func (r *Registry) WaitForScan(name, reference string, timeout time.Duration) (*ScanResult, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		result, err := r.ScanFindings(name, reference)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil && result.Status != ScanInProgress {
+			return result, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timed out waiting for the scan of %s:%s", name, reference)
+		}
+		time.Sleep(scanPollInterval)
+	}
+}
+
+// ImageReference names an image in a repository by tag or digest
+type ImageReference struct {
+	Name      string
+	Reference string
+}
+
+func (i ImageReference) String() string {
+	if strings.HasPrefix(i.Reference, "sha256:") {
+		return i.Name + "@" + i.Reference
+	}
+	return i.Name + ":" + i.Reference
+}
+
+// WaitForScans waits for the scans of the images, which run at the same
+// time, to finish. It returns the most recent scan of each, which may still
+// be in progress if the timeout passed first.
+func (r *Registry) WaitForScans(images []ImageReference, timeout time.Duration) (map[ImageReference]*ScanResult, error) {
+	deadline := time.Now().Add(timeout)
+	results := make(map[ImageReference]*ScanResult)
+	pending := images
+	for {
+		remaining := make([]ImageReference, 0, len(pending))
+		for _, image := range pending {
+			result, err := r.ScanFindings(image.Name, image.Reference)
+			if err != nil {
+				return nil, err
+			}
+			results[image] = result
+			if result == nil || result.Status == ScanInProgress {
+				remaining = append(remaining, image)
+			}
+		}
+		if len(remaining) == 0 || time.Now().After(deadline) {
+			return results, nil
+		}
+		pending = remaining
+		time.Sleep(scanPollInterval)
+	}
+}
+
+// ScanGate decides which images may be deployed from their scan findings
+type ScanGate struct {
+	// Thresholds are the most findings of each severity an image may have
+	Thresholds map[string]int `yaml:"thresholds"`
+	// Ignore lists the findings, by CVE ID, to leave out for each app, or
+	// for every app under "*"
+	Ignore map[string][]string `yaml:"ignore"`
+}
+
+// ParseThreshold reads SEVERITY[:MAX], where MAX defaults to 0 so that any
+// finding of that severity blocks
+func ParseThreshold(s string) (string, int, error) {
+	parts := strings.SplitN(s, ":", 2)
+	severity := strings.ToUpper(parts[0])
+	known := false
+	for _, sev := range Severities {
+		if sev == severity {
+			known = true
+		}
+	}
+	if !known {
+		return "", 0, fmt.Errorf("Unknown severity %s, expected one of %s", parts[0], strings.Join(Severities, ", "))
+	}
+	max := 0
+	if len(parts) == 2 {
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 0 {
+			return "", 0, fmt.Errorf("Threshold %s must be SEVERITY[:MAX]", s)
+		}
+		max = n
+	}
+	return severity, max, nil
+}
+
+// IsSet returns whether the gate blocks anything
+func (g *ScanGate) IsSet() bool {
+	return len(g.Thresholds) > 0
+}
+
+// Check returns why the app must not deploy the scanned image, or "" if it
+// may. An image that has not been scanned, or whose scan did not complete,
+// is blocked.
+func (g *ScanGate) Check(app string, scan *ScanResult) string {
+	if scan == nil {
+		return "not scanned"
+	}
+	switch scan.Status {
+	case ScanComplete:
+	case ScanInProgress:
+		return "scan in progress"
+	default:
+		reason := "scan " + strings.ToLower(scan.Status)
+		if scan.Description != "" {
+			reason += ": " + scan.Description
+		}
+		return reason
+	}
+	ignore := make(map[string]bool)
+	for _, key := range []string{"*", app} {
+		for _, id := range g.Ignore[key] {
+			ignore[id] = true
+		}
+	}
+	counts := scan.Counts(ignore)
+	reasons := make([]string, 0)
+	for _, severity := range Severities {
+		max, ok := g.Thresholds[severity]
+		if ok && counts[severity] > max {
+			reasons = append(reasons, fmt.Sprintf("%d %s (at most %d allowed)", counts[severity], severity, max))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
@@ -0,0 +1,62 @@
+package ecr
+
+import "testing"
+
+func TestParseThreshold(T *testing.T) {
+	tests := []struct {
+		in       string
+		severity string
+		max      int
+	}{
+		{"CRITICAL", "CRITICAL", 0},
+		{"high:5", "HIGH", 5},
+		{"MEDIUM:0", "MEDIUM", 0},
+	}
+	for _, t := range tests {
+		severity, max, err := ParseThreshold(t.in)
+		if err != nil || severity != t.severity || max != t.max {
+			T.Errorf("%s: got %s %d %v", t.in, severity, max, err)
+		}
+	}
+	for _, in := range []string{"SEVERE", "HIGH:lots", "HIGH:-1"} {
+		if _, _, err := ParseThreshold(in); err == nil {
+			T.Errorf("Expected %s to be refused", in)
+		}
+	}
+}
+
+func TestScanGateCheck(T *testing.T) {
+	gate := &ScanGate{
+		Thresholds: map[string]int{"CRITICAL": 0, "HIGH": 1},
+		Ignore: map[string][]string{
+			"*":   {"CVE-2019-0001"},
+			"web": {"CVE-2019-0002"},
+		},
+	}
+	scan := &ScanResult{
+		Status: ScanComplete,
+		Vulnerabilities: []Vulnerability{
+			{Name: "CVE-2019-0001", Severity: "CRITICAL"},
+			{Name: "CVE-2019-0002", Severity: "CRITICAL"},
+			{Name: "CVE-2019-0003", Severity: "HIGH"},
+			{Name: "CVE-2019-0004", Severity: "HIGH"},
+			{Name: "CVE-2019-0005", Severity: "MEDIUM"},
+		},
+	}
+	if reason := gate.Check("web", scan); reason != "2 HIGH (at most 1 allowed)" {
+		T.Errorf("Unexpected reason for web: %q", reason)
+	}
+	if reason := gate.Check("worker", scan); reason != "1 CRITICAL (at most 0 allowed), 2 HIGH (at most 1 allowed)" {
+		T.Errorf("Unexpected reason for worker: %q", reason)
+	}
+	gate.Thresholds["HIGH"] = 2
+	if reason := gate.Check("web", scan); reason != "" {
+		T.Errorf("Expected web to be allowed, got %q", reason)
+	}
+	if reason := gate.Check("web", nil); reason != "not scanned" {
+		T.Errorf("Expected an unscanned image to be blocked, got %q", reason)
+	}
+	if reason := gate.Check("web", &ScanResult{Status: ScanFailed, Description: "Unsupported image"}); reason != "scan failed: Unsupported image" {
+		T.Errorf("Expected a failed scan to be blocked, got %q", reason)
+	}
+}
@@ -0,0 +1,80 @@
+package ecr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	docker "docker.io/go-docker"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// How a tag is pushed
+const (
+	pushUpload = "upload" // The image is not in the repository
+	pushTag    = "tag"    // The image is in the repository, and only needs tagging
+	pushSkip   = "skip"   // The tag is already on the image
+)
+
+// repositoryImages is what a repository already holds
+type repositoryImages struct {
+	immutable bool
+	digests   map[string]bool
+	tags      map[string]string // Map of tags to image digests
+}
+
+func (r *Registry) repositoryImages(name string) (*repositoryImages, error) {
+	repo, err := r.describeRepository(name)
+	if err != nil {
+		return nil, err
+	}
+	images, err := r.Images(name)
+	if err != nil {
+		return nil, err
+	}
+	ri := &repositoryImages{
+		immutable: aws.StringValue(repo.ImageTagMutability) == ecr.ImageTagMutabilityImmutable,
+		digests:   make(map[string]bool),
+		tags:      make(map[string]string),
+	}
+	for _, image := range images {
+		ri.digests[image.Digest] = true
+		for _, t := range image.Tags {
+			ri.tags[t] = image.Digest
+		}
+	}
+	return ri, nil
+}
+
+// plan decides how to push the tag of an image whose manifest digest is
+// digest, or "" if it is not known. Moving a tag in a repository with
+// immutable tags is an error, rather than a failure part way through a push.
+func (ri *repositoryImages) plan(tag, digest string) (string, error) {
+	current, tagged := ri.tags[tag]
+	if tagged && digest != "" && current == digest {
+		return pushSkip, nil
+	}
+	if tagged && ri.immutable {
+		return "", fmt.Errorf("Tag %s is on %s and the repository's tags are immutable", tag, current)
+	}
+	if digest != "" && ri.digests[digest] {
+		return pushTag, nil
+	}
+	return pushUpload, nil
+}
+
+// localDigest returns the digest the image had when it was last pushed to
+// or pulled from the repository, or "" if it never was
+func localDigest(client *docker.Client, image, repository string) (string, error) {
+	inspect, _, err := client.ImageInspectWithRaw(context.Background(), image)
+	if err != nil {
+		return "", err
+	}
+	for _, d := range inspect.RepoDigests {
+		if strings.HasPrefix(d, repository+"@") {
+			return d[len(repository)+1:], nil
+		}
+	}
+	return "", nil
+}
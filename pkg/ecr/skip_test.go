@@ -0,0 +1,38 @@
+package ecr
+
+import "testing"
+
+func TestPlanPush(T *testing.T) {
+	images := &repositoryImages{
+		digests: map[string]bool{"sha256:aaa": true, "sha256:bbb": true},
+		tags:    map[string]string{"1.0.0": "sha256:aaa", "latest": "sha256:aaa"},
+	}
+	tests := []struct{ tag, digest, expected string }{
+		{"1.0.0", "sha256:aaa", pushSkip},
+		{"1.0.1", "sha256:aaa", pushTag},
+		{"1.0.1", "sha256:bbb", pushTag},
+		{"latest", "sha256:bbb", pushTag},
+		{"1.0.1", "sha256:ccc", pushUpload},
+		{"1.0.1", "", pushUpload},
+		{"latest", "", pushUpload},
+	}
+	for _, t := range tests {
+		action, err := images.plan(t.tag, t.digest)
+		if err != nil || action != t.expected {
+			T.Errorf("%s %s: expected %s, got %s %v", t.tag, t.digest, t.expected, action, err)
+		}
+	}
+
+	images.immutable = true
+	if action, _ := images.plan("1.0.0", "sha256:aaa"); action != pushSkip {
+		T.Errorf("Expected an unchanged tag to be skipped, got %s", action)
+	}
+	if action, _ := images.plan("1.0.1", "sha256:bbb"); action != pushTag {
+		T.Errorf("Expected a new tag to be added, got %s", action)
+	}
+	for _, digest := range []string{"sha256:bbb", ""} {
+		if _, err := images.plan("latest", digest); err == nil {
+			T.Errorf("Expected moving an immutable tag to %q to fail", digest)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package ecr
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// Sync actions
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// ClusterGrant gives the principals of a cluster read access
+type ClusterGrant struct {
+	Cluster    string
+	Principals []string
+}
+
+// RepositorySpec declares a repository, its settings and the clusters that
+// may pull from it
+type RepositorySpec struct {
+	Name    string
+	Options RepositoryOptions
+	Grants  []ClusterGrant
+}
+
+// RepositoryState is what ECR currently has for a repository
+type RepositoryState struct {
+	Repository *ecr.Repository
+	Tags       map[string]string
+	Lifecycle  string
+	Policy     string
+}
+
+// SyncChange is a repository that sync will create, update or delete
+type SyncChange struct {
+	Repository string
+	Action     string
+	Reasons    []string
+	Spec       *RepositorySpec
+}
+
+// getLifecyclePolicy returns the lifecycle policy text, or "" if there is none
+func (r *Registry) getLifecyclePolicy(name string) (string, error) {
+	response, err := r.service.GetLifecyclePolicy(&ecr.GetLifecyclePolicyInput{
+		RepositoryName: aws.String(name),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeLifecyclePolicyNotFoundException {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(response.LifecyclePolicyText), nil
+}
+
+// RepositoryStates fetches the settings, tags and policies of every repository
+func (r *Registry) RepositoryStates() (map[string]*RepositoryState, error) {
+	repos := make([]*ecr.Repository, 0)
+	err := r.service.DescribeRepositoriesPages(&ecr.DescribeRepositoriesInput{},
+		func(page *ecr.DescribeRepositoriesOutput, lastPage bool) bool {
+			repos = append(repos, page.Repositories...)
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]*RepositoryState)
+	for _, repo := range repos {
+		name := aws.StringValue(repo.RepositoryName)
+		state := &RepositoryState{Repository: repo, Tags: make(map[string]string)}
+		tags, err := r.service.ListTagsForResource(&ecr.ListTagsForResourceInput{
+			ResourceArn: repo.RepositoryArn,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tags.Tags {
+			state.Tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+		}
+		if state.Lifecycle, err = r.getLifecyclePolicy(name); err != nil {
+			return nil, err
+		}
+		if state.Policy, err = r.getPolicy(name); err != nil {
+			return nil, err
+		}
+		states[name] = state
+	}
+	return states, nil
+}
+
+func sameLifecycle(a, b string) bool {
+	var pa, pb lifecyclePolicy
+	if json.Unmarshal([]byte(a), &pa) != nil || json.Unmarshal([]byte(b), &pb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(pa, pb)
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool)
+	for _, s := range a {
+		seen[s] = true
+	}
+	for _, s := range b {
+		if !seen[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// drift describes how the repository differs from its spec, and warns of
+// differences that cannot be changed. Settings the spec does not mention,
+// such as undeclared tags or an unset scan on push, are left alone.
+func drift(spec *RepositorySpec, state *RepositoryState) ([]string, []string, error) {
+	reasons := make([]string, 0)
+	warnings := make([]string, 0)
+	repo := state.Repository
+	options := spec.Options
+	if scan := scanOnPush(repo); options.ScanOnPush != nil && scan != *options.ScanOnPush {
+		reasons = append(reasons, fmt.Sprintf("scan on push %v -> %v", scan, *options.ScanOnPush))
+	}
+	if mutability := aws.StringValue(repo.ImageTagMutability); options.ImmutableTags != nil && mutability != options.mutability() {
+		reasons = append(reasons, fmt.Sprintf("tag mutability %s -> %s", mutability, options.mutability()))
+	}
+	// Encryption is only compared when the spec asks for KMS
+	if actual := repo.EncryptionConfiguration; actual != nil && (options.KMS || options.KMSKey != "") {
+		wanted := options.encryption()
+		if aws.StringValue(actual.EncryptionType) != aws.StringValue(wanted.EncryptionType) ||
+			(wanted.KmsKey != nil && aws.StringValue(actual.KmsKey) != aws.StringValue(wanted.KmsKey)) {
+			warnings = append(warnings, fmt.Sprintf("%s is encrypted with %s, which cannot be changed", spec.Name, aws.StringValue(actual.EncryptionType)))
+		}
+	}
+	for _, t := range options.tags() {
+		if value, ok := state.Tags[*t.Key]; !ok || value != *t.Value {
+			reasons = append(reasons, fmt.Sprintf("tag %s=%s", *t.Key, *t.Value))
+		}
+	}
+	if len(options.Lifecycle) > 0 {
+		wanted, err := LifecyclePolicy(options.Lifecycle)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !sameLifecycle(wanted, state.Lifecycle) {
+			reasons = append(reasons, "lifecycle policy")
+		}
+	}
+	policy, err := parsePolicy(state.Policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, grant := range spec.Grants {
+		statement := policy.find(clusterSid(grant.Cluster))
+		if statement == nil {
+			reasons = append(reasons, fmt.Sprintf("grant %s", grant.Cluster))
+		} else if !sameSet(statement.principals(), grant.Principals) || !sameSet(statement.Action, pullActions) {
+			reasons = append(reasons, fmt.Sprintf("update grant %s", grant.Cluster))
+		}
+	}
+	return reasons, warnings, nil
+}
+
+// PlanSync compares the declared repositories with ECR, returning the
+// changes needed to make them match, and warnings of differences that no
+// change can fix. With prune, repositories that are not declared are deleted.
+func PlanSync(specs []RepositorySpec, states map[string]*RepositoryState, prune bool) ([]SyncChange, []string, error) {
+	changes := make([]SyncChange, 0)
+	warnings := make([]string, 0)
+	declared := make(map[string]bool)
+	for i := range specs {
+		spec := &specs[i]
+		if declared[spec.Name] {
+			return nil, nil, fmt.Errorf("Repository %s is declared twice", spec.Name)
+		}
+		declared[spec.Name] = true
+		for _, grant := range spec.Grants {
+			if err := checkPrincipals(grant.Principals); err != nil {
+				return nil, nil, fmt.Errorf("%s: %s", spec.Name, err)
+			}
+		}
+		state, ok := states[spec.Name]
+		if !ok {
+			changes = append(changes, SyncChange{Repository: spec.Name, Action: SyncCreate, Spec: spec})
+			continue
+		}
+		reasons, unfixable, err := drift(spec, state)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", spec.Name, err)
+		}
+		warnings = append(warnings, unfixable...)
+		if len(reasons) > 0 {
+			changes = append(changes, SyncChange{Repository: spec.Name, Action: SyncUpdate, Reasons: reasons, Spec: spec})
+		}
+	}
+	if prune {
+		for name := range states {
+			if !declared[name] {
+				changes = append(changes, SyncChange{Repository: name, Action: SyncDelete})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Repository < changes[j].Repository
+	})
+	return changes, warnings, nil
+}
+
+// ApplySync makes the change to ECR. Deleting a repository deletes its images.
+func (r *Registry) ApplySync(change SyncChange) error {
+	if change.Action == SyncDelete {
+		_, err := r.service.DeleteRepository(&ecr.DeleteRepositoryInput{
+			RepositoryName: aws.String(change.Repository),
+			Force:          aws.Bool(true),
+		})
+		return err
+	}
+	if _, err := r.EnsureRepository(change.Repository, change.Spec.Options); err != nil {
+		return err
+	}
+	for _, grant := range change.Spec.Grants {
+		if err := r.Grant(change.Repository, grant.Cluster, grant.Principals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package ecr
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+func TestPlanSync(T *testing.T) {
+	nodes := []string{"arn:aws:iam::123456789012:role/nodes"}
+	lifecycle, err := LifecyclePolicy([]string{"expire-untagged"})
+	if err != nil {
+		T.Fatal(err)
+	}
+	policy, err := mergeStatement(existingPolicy, clusterStatement("prod.example.com", nodes))
+	if err != nil {
+		T.Fatal(err)
+	}
+	states := map[string]*RepositoryState{
+		"web": {
+			Repository: &ecr.Repository{
+				RepositoryName:             aws.String("web"),
+				ImageScanningConfiguration: &ecr.ImageScanningConfiguration{ScanOnPush: aws.Bool(true)},
+				ImageTagMutability:         aws.String(ecr.ImageTagMutabilityMutable),
+			},
+			Tags:      map[string]string{"team": "web", "other": "kept"},
+			Lifecycle: lifecycle,
+			Policy:    policy,
+		},
+		"api": {
+			Repository: &ecr.Repository{
+				RepositoryName:     aws.String("api"),
+				ImageTagMutability: aws.String(ecr.ImageTagMutabilityMutable),
+			},
+			Tags:   map[string]string{},
+			Policy: existingPolicy,
+		},
+		"old": {
+			Repository: &ecr.Repository{RepositoryName: aws.String("old")},
+		},
+	}
+	options := RepositoryOptions{
+		ScanOnPush: aws.Bool(true),
+		Tags:       map[string]string{"team": "web"},
+		Lifecycle:  []string{"expire-untagged"},
+	}
+	grants := []ClusterGrant{{Cluster: "prod.example.com", Principals: nodes}}
+	specs := []RepositorySpec{
+		{Name: "web", Options: options, Grants: grants},
+		{Name: "api", Options: options, Grants: grants},
+		{Name: "new", Options: options, Grants: grants},
+	}
+	changes, _, err := PlanSync(specs, states, false)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(changes) != 2 {
+		T.Fatalf("Expected 2 changes, got %v", changes)
+	}
+	if changes[0].Repository != "api" || changes[0].Action != SyncUpdate {
+		T.Errorf("Expected api to be updated, got %v", changes[0])
+	}
+	expected := []string{"scan on push false -> true", "tag team=web", "lifecycle policy", "update grant prod.example.com"}
+	if len(changes[0].Reasons) != len(expected) {
+		T.Fatalf("Expected %v, got %v", expected, changes[0].Reasons)
+	}
+	for i := range expected {
+		if changes[0].Reasons[i] != expected[i] {
+			T.Errorf("Expected %s, got %s", expected[i], changes[0].Reasons[i])
+		}
+	}
+	if changes[1].Repository != "new" || changes[1].Action != SyncCreate {
+		T.Errorf("Expected new to be created, got %v", changes[1])
+	}
+
+	changes, _, err = PlanSync(specs, states, true)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(changes) != 3 || changes[2].Repository != "old" || changes[2].Action != SyncDelete {
+		T.Errorf("Expected old to be deleted, got %v", changes)
+	}
+
+	unset := []RepositorySpec{{Name: "api", Grants: grants, Options: RepositoryOptions{Tags: map[string]string{}}}}
+	changes, _, err = PlanSync(unset, states, false)
+	if err != nil || len(changes) != 1 || len(changes[0].Reasons) != 1 {
+		T.Errorf("Settings that are not declared should be left alone, got %v %v", changes, err)
+	}
+
+	specs = append(specs, RepositorySpec{Name: "web"})
+	if _, _, err := PlanSync(specs, states, false); err == nil {
+		T.Error("Expected an error for a repository declared twice")
+	}
+
+	encrypted := map[string]*RepositoryState{
+		"web": {
+			Repository: &ecr.Repository{
+				RepositoryName:          aws.String("web"),
+				EncryptionConfiguration: &ecr.EncryptionConfiguration{EncryptionType: aws.String(ecr.EncryptionTypeKms)},
+			},
+		},
+	}
+	changes, warnings, err := PlanSync([]RepositorySpec{{Name: "web"}}, encrypted, false)
+	if err != nil || len(changes) != 0 || len(warnings) != 0 {
+		T.Errorf("Encryption should not be compared unless KMS is asked for: %v %v %v", changes, warnings, err)
+	}
+	kms := RepositorySpec{Name: "web"}
+	kms.Options.KMSKey = "arn:aws:kms:eu-west-2:123456789012:key/other"
+	changes, warnings, err = PlanSync([]RepositorySpec{kms}, encrypted, false)
+	if err != nil || len(changes) != 0 || len(warnings) != 1 {
+		T.Errorf("Encryption cannot be changed, so should only be warned about: %v %v %v", changes, warnings, err)
+	}
+}
+
+func TestPolicyStatementPrincipals(T *testing.T) {
+	p, err := parsePolicy(`{"Statement": {"Sid": "a", "Principal": "*", "Action": "ecr:*"}}`)
+	if err != nil {
+		T.Fatal(err)
+	}
+	s := p.find("a")
+	if s == nil || len(s.principals()) != 1 || s.principals()[0] != "*" || s.Action[0] != "ecr:*" {
+		T.Errorf("Unexpected statement %v", s)
+	}
+	p, err = parsePolicy(existingPolicy)
+	if err != nil {
+		T.Fatal(err)
+	}
+	if s := p.find("CI push"); s == nil || s.principals()[0] != "arn:aws:iam::123456789012:role/ci" {
+		T.Errorf("Unexpected statement %v", s)
+	}
+	if p.find("missing") != nil {
+		T.Error("Expected no statement")
+	}
+}
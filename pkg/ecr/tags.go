@@ -0,0 +1,175 @@
+package ecr
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// TagStrategy derives the tags of a push from the tags already in the
+// repository and the git checkout
+type TagStrategy struct {
+	Bump   string // patch, minor or major
+	GitSHA bool
+	Alias  bool // Also tag MAJOR and MAJOR.MINOR
+}
+
+// IsSet returns whether the strategy derives any tags
+func (s TagStrategy) IsSet() bool {
+	return s.Bump != "" || s.GitSHA || s.Alias
+}
+
+// GitTagPrefix is put before commit hashes, so that tags such as 1234567
+// are not taken for versions
+const GitTagPrefix = "git-"
+
+// GitSHA returns the short commit hash of the checkout in the current directory
+func GitSHA() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("Cannot read the git commit: %s", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// fullVersion matches MAJOR.MINOR.PATCH versions, with an optional "v",
+// prerelease and build. The semver parser also accepts "1.5", which may be
+// an alias, and "1234567", which may be a commit hash.
+var fullVersion = regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+([-+].*)?$`)
+
+// releaseVersions returns the tags that are full versions and not
+// prereleases, lowest first. Equal versions, such as 1.0.0 and v1.0.0, are
+// always in the same order.
+func releaseVersions(tags []string) []*semver.Version {
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	versions := make([]*semver.Version, 0)
+	for _, t := range sorted {
+		if !fullVersion.MatchString(t) {
+			continue
+		}
+		if v, err := semver.NewVersion(t); err == nil && v.Prerelease() == "" {
+			versions = append(versions, v)
+		}
+	}
+	sort.Stable(semver.Collection(versions))
+	return versions
+}
+
+// bump increments the highest released version among the tags, keeping a
+// "v" prefix if it has one
+func bump(existing []string, part string) (string, error) {
+	prefix, major, minor, patch := "", int64(0), int64(0), int64(0)
+	if releases := releaseVersions(existing); len(releases) > 0 {
+		v := releases[len(releases)-1]
+		major, minor, patch = v.Major(), v.Minor(), v.Patch()
+		if strings.HasPrefix(v.Original(), "v") {
+			prefix = "v"
+		}
+	}
+	switch part {
+	case "major":
+		major, minor, patch = major+1, 0, 0
+	case "minor":
+		minor, patch = minor+1, 0
+	case "patch":
+		patch++
+	default:
+		return "", fmt.Errorf("Cannot bump %s, expected patch, minor or major", part)
+	}
+	return fmt.Sprintf("%s%d.%d.%d", prefix, major, minor, patch), nil
+}
+
+// aliases returns MAJOR and MAJOR.MINOR for a release version, unless a
+// higher version of that line already exists
+func aliases(tag string, existing []*semver.Version) []string {
+	versions := releaseVersions([]string{tag})
+	if len(versions) == 0 {
+		return nil
+	}
+	v := versions[0]
+	prefix := ""
+	if strings.HasPrefix(tag, "v") {
+		prefix = "v"
+	}
+	highestMajor, highestMinor := true, true
+	for _, e := range existing {
+		if !e.GreaterThan(v) {
+			continue
+		}
+		if e.Major() == v.Major() {
+			highestMajor = false
+			if e.Minor() == v.Minor() {
+				highestMinor = false
+			}
+		}
+	}
+	result := make([]string, 0, 2)
+	if highestMajor {
+		result = append(result, fmt.Sprintf("%s%d", prefix, v.Major()))
+	}
+	if highestMinor {
+		result = append(result, fmt.Sprintf("%s%d.%d", prefix, v.Major(), v.Minor()))
+	}
+	return result
+}
+
+// Tags returns the tags to push: the explicit tags, a bumped version, the
+// git commit and aliases of every version, without duplicates
+func (s TagStrategy) Tags(explicit, existing []string, sha string) ([]string, error) {
+	tags := append([]string{}, explicit...)
+	if s.Bump != "" {
+		version, err := bump(existing, s.Bump)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, version)
+	}
+	if s.Alias {
+		versions := releaseVersions(existing)
+		for _, t := range tags {
+			tags = append(tags, aliases(t, versions)...)
+		}
+	}
+	if s.GitSHA {
+		if sha == "" {
+			return nil, errors.New("No git commit to tag")
+		}
+		tags = append(tags, GitTagPrefix+sha)
+	}
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, errors.New("No tags to push")
+	}
+	return unique, nil
+}
+
+// Tags returns the tags in the repository
+func (r *Registry) Tags(name string) ([]string, error) {
+	tags, _, err := getTagsForRepository(r.service, name)
+	return tags, err
+}
+
+// Latest returns the highest version tagged in the repository
+func (r *Registry) Latest(name string) (string, error) {
+	tags, err := r.Tags(name)
+	if err != nil {
+		return "", err
+	}
+	if len(tags) == 0 {
+		return "", fmt.Errorf("%s has no tagged images", name)
+	}
+	return latestVersion(tags), nil
+}
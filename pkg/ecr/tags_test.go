@@ -0,0 +1,59 @@
+package ecr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagStrategy(T *testing.T) {
+	existing := []string{"1.3.9", "1.4.1", "1.4.2", "2.0.0-rc1", "latest"}
+	tests := []struct {
+		strategy TagStrategy
+		explicit []string
+		expected string
+	}{
+		{TagStrategy{Bump: "patch"}, nil, "1.4.3"},
+		{TagStrategy{Bump: "minor", Alias: true}, nil, "1.5.0 1 1.5"},
+		{TagStrategy{Bump: "major", GitSHA: true}, []string{"latest"}, "latest 2.0.0 git-abc1234"},
+		{TagStrategy{Alias: true}, []string{"1.3.10"}, "1.3.10 1.3"},
+		{TagStrategy{Alias: true}, []string{"1.4.3", "latest"}, "1.4.3 latest 1 1.4"},
+	}
+	for _, t := range tests {
+		tags, err := t.strategy.Tags(t.explicit, existing, "abc1234")
+		if err != nil {
+			T.Fatal(err)
+		}
+		if strings.Join(tags, " ") != t.expected {
+			T.Errorf("%v: expected %s, got %v", t.strategy, t.expected, tags)
+		}
+	}
+	if tags, _ := (TagStrategy{Bump: "minor"}).Tags(nil, []string{"v0.9.1"}, ""); tags[0] != "v0.10.0" {
+		T.Errorf("Expected the v prefix to be kept, got %v", tags)
+	}
+	if tags, _ := (TagStrategy{Bump: "patch"}).Tags(nil, []string{"1.4.2", "1.5", "1", "1234567", "git-2345678"}, ""); tags[0] != "1.4.3" {
+		T.Errorf("Expected aliases and commit hashes to be ignored, got %v", tags)
+	}
+	if tags, _ := (TagStrategy{Bump: "patch"}).Tags(nil, nil, ""); tags[0] != "0.0.1" {
+		T.Errorf("Expected a first version, got %v", tags)
+	}
+	if _, err := (TagStrategy{Bump: "huge"}).Tags(nil, nil, ""); err == nil {
+		T.Error("Expected an error for an unknown bump")
+	}
+	if _, err := (TagStrategy{}).Tags(nil, nil, ""); err == nil {
+		T.Error("Expected an error with no tags")
+	}
+}
+
+func TestLatestVersion(T *testing.T) {
+	for expected, tags := range map[string][]string{
+		"10":     {"9", "10", "latest"},
+		"1.10":   {"1.9", "1.10"},
+		"1.5.0":  {"1.4.9", "1.5.0", "1.3"},
+		"stable": {"latest", "stable"},
+		"":       {},
+	} {
+		if actual := latestVersion(tags); actual != expected {
+			T.Errorf("latestVersion(%v) is %s, expected %s", tags, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Client talks to a registry using the Docker Registry HTTP API V2
+type Client struct {
+	Host     string
+	Username string
+	Password string
+	Scheme   string // https, unless talking to a local test registry
+	HTTP     *http.Client
+	// Progress, if set, is called as each blob is checked and uploaded
+	Progress func(digest, status string)
+
+	mu        sync.Mutex
+	challenge *challenge
+	tokens    map[string]string
+}
+
+// New returns a client for the registry at host, authenticating with the
+// username and password if the registry asks
+func New(host, username, password string) *Client {
+	return &Client{
+		Host:     host,
+		Username: username,
+		Password: password,
+		Scheme:   "https",
+		HTTP:     http.DefaultClient,
+		tokens:   make(map[string]string),
+	}
+}
+
+// Error is an error response from the registry
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("Registry returned %d", e.Status)
+	}
+	return fmt.Sprintf("Registry returned %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Digest returns the sha256 digest of the content
+func Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func pullScope(repo string) string {
+	return fmt.Sprintf("repository:%s:pull", repo)
+}
+
+func pushScope(repo string) string {
+	return fmt.Sprintf("repository:%s:pull,push", repo)
+}
+
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// parseChallenge reads a WWW-Authenticate header such as
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseChallenge(header string) *challenge {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if parts[0] == "" {
+		return nil
+	}
+	c := &challenge{scheme: strings.ToLower(parts[0]), params: make(map[string]string)}
+	if len(parts) == 2 {
+		for _, m := range challengeParam.FindAllStringSubmatch(parts[1], -1) {
+			c.params[strings.ToLower(m[1])] = m[2]
+		}
+	}
+	return c
+}
+
+// token fetches a bearer token for the scope from the registry's auth server
+func (c *Client) token(ch *challenge, scope string) (string, error) {
+	c.mu.Lock()
+	token, ok := c.tokens[scope]
+	c.mu.Unlock()
+	if ok {
+		return token, nil
+	}
+	realm, err := url.Parse(ch.params["realm"])
+	if err != nil || ch.params["realm"] == "" {
+		return "", fmt.Errorf("Registry %s has no usable token realm", c.Host)
+	}
+	query := realm.Query()
+	if service, ok := ch.params["service"]; ok {
+		query.Set("service", service)
+	}
+	for _, s := range strings.Fields(scope) {
+		query.Add("scope", s)
+	}
+	realm.RawQuery = query.Encode()
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Token server for %s returned %d", c.Host, resp.StatusCode)
+	}
+	response := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	token = response.Token
+	if token == "" {
+		token = response.AccessToken
+	}
+	c.mu.Lock()
+	c.tokens[scope] = token
+	c.mu.Unlock()
+	return token, nil
+}
+
+func (c *Client) authorize(req *http.Request, scope string) error {
+	c.mu.Lock()
+	ch := c.challenge
+	c.mu.Unlock()
+	if ch == nil {
+		return nil
+	}
+	switch ch.scheme {
+	case "basic":
+		if c.Username != "" {
+			req.SetBasicAuth(c.Username, c.Password)
+		}
+	case "bearer":
+		token, err := c.token(ch, scope)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// payload is a request body that can be sent again after authenticating
+type payload struct {
+	open func() (io.ReadCloser, error)
+	size int64
+}
+
+func bytesPayload(b []byte) *payload {
+	return &payload{
+		open: func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(b)), nil },
+		size: int64(len(b)),
+	}
+}
+
+// do sends the request, authenticating and trying again if the registry asks
+func (c *Client) do(method, u string, header http.Header, body *payload, scope string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			if req.Body, err = body.open(); err != nil {
+				return nil, err
+			}
+			req.ContentLength = body.size
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if err := c.authorize(req, scope); err != nil {
+			return nil, err
+		}
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+		ch := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+		resp.Body.Close()
+		if ch == nil {
+			return nil, &Error{Status: http.StatusUnauthorized, Code: "UNAUTHORIZED", Message: "no authentication challenge"}
+		}
+		c.mu.Lock()
+		c.challenge = ch
+		delete(c.tokens, scope)
+		c.mu.Unlock()
+	}
+}
+
+// check returns an Error, and closes the response, unless the status is expected
+func check(resp *http.Response, expected ...int) error {
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	defer resp.Body.Close()
+	e := &Error{Status: resp.StatusCode}
+	response := struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	if json.NewDecoder(resp.Body).Decode(&response) == nil && len(response.Errors) > 0 {
+		e.Code = response.Errors[0].Code
+		e.Message = response.Errors[0].Message
+	}
+	return e
+}
+
+func (c *Client) url(format string, args ...interface{}) string {
+	return c.Scheme + "://" + c.Host + fmt.Sprintf(format, args...)
+}
+
+// location resolves the Location header of the response
+func location(resp *http.Response) (*url.URL, error) {
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return nil, fmt.Errorf("Registry returned %d without a location", resp.StatusCode)
+	}
+	return resp.Request.URL.Parse(loc)
+}
+
+// BlobExists returns whether the repository has the blob
+func (c *Client) BlobExists(repo, digest string) (bool, error) {
+	resp, err := c.do("HEAD", c.url("/v2/%s/blobs/%s", repo, digest), nil, nil, pullScope(repo))
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return false, nil
+	}
+	if err := check(resp, http.StatusOK); err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// GetBlob opens the blob, returning its size
+func (c *Client) GetBlob(repo, digest string) (io.ReadCloser, int64, error) {
+	resp, err := c.do("GET", c.url("/v2/%s/blobs/%s", repo, digest), nil, nil, pullScope(repo))
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := check(resp, http.StatusOK); err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// UploadBlob uploads the content opened by open, which must have the digest
+func (c *Client) UploadBlob(repo, digest string, size int64, open func() (io.ReadCloser, error)) error {
+	resp, err := c.do("POST", c.url("/v2/%s/blobs/uploads/", repo), nil, nil, pushScope(repo))
+	if err != nil {
+		return err
+	}
+	if err := check(resp, http.StatusAccepted); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	loc, err := location(resp)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err = c.do("PATCH", loc.String(), header, &payload{open: open, size: size}, pushScope(repo))
+	if err != nil {
+		return err
+	}
+	if err := check(resp, http.StatusAccepted); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if loc, err = location(resp); err != nil {
+		return err
+	}
+
+	query := loc.Query()
+	query.Set("digest", digest)
+	loc.RawQuery = query.Encode()
+	resp, err = c.do("PUT", loc.String(), nil, bytesPayload(nil), pushScope(repo))
+	if err != nil {
+		return err
+	}
+	if err := check(resp, http.StatusCreated); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// MountBlob asks the registry to link a blob from another of its
+// repositories, returning false if it did not
+func (c *Client) MountBlob(repo, digest, from string) (bool, error) {
+	u := c.url("/v2/%s/blobs/uploads/?mount=%s&from=%s", repo, url.QueryEscape(digest), url.QueryEscape(from))
+	resp, err := c.do("POST", u, nil, nil, pushScope(repo)+" "+pullScope(from))
+	if err != nil {
+		return false, err
+	}
+	if err := check(resp, http.StatusCreated, http.StatusAccepted); err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusCreated, nil
+}
+
+// GetManifest fetches the manifest with the tag or digest
+func (c *Client) GetManifest(repo, reference string) (*RawManifest, error) {
+	header := http.Header{"Accept": manifestTypes}
+	resp, err := c.do("GET", c.url("/v2/%s/manifests/%s", repo, reference), header, nil, pullScope(repo))
+	if err != nil {
+		return nil, err
+	}
+	if err := check(resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	m := &RawManifest{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    resp.Header.Get("Docker-Content-Digest"),
+		Body:      body,
+	}
+	if m.Digest == "" {
+		m.Digest = Digest(body)
+	}
+	if i := strings.Index(m.MediaType, ";"); i >= 0 {
+		m.MediaType = m.MediaType[:i]
+	}
+	return m, nil
+}
+
+// PutManifest stores the manifest under the tag or digest
+func (c *Client) PutManifest(repo, reference string, m *RawManifest) error {
+	header := http.Header{"Content-Type": {m.MediaType}}
+	resp, err := c.do("PUT", c.url("/v2/%s/manifests/%s", repo, reference), header, bytesPayload(m.Body), pushScope(repo))
+	if err != nil {
+		return err
+	}
+	if err := check(resp, http.StatusCreated, http.StatusOK); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Tags lists the tags of the repository
+func (c *Client) Tags(repo string) ([]string, error) {
+	tags := make([]string, 0)
+	u := c.url("/v2/%s/tags/list", repo)
+	for u != "" {
+		resp, err := c.do("GET", u, nil, nil, pullScope(repo))
+		if err != nil {
+			return nil, err
+		}
+		if err := check(resp, http.StatusOK); err != nil {
+			return nil, err
+		}
+		page := struct {
+			Tags []string `json:"tags"`
+		}{}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+		u = ""
+		// The next page is given as Link: </v2/...?last=x&n=y>; rel="next"
+		if link := resp.Header.Get("Link"); strings.Contains(link, `rel="next"`) {
+			start, end := strings.Index(link, "<"), strings.Index(link, ">")
+			if start >= 0 && end > start {
+				next, err := resp.Request.URL.Parse(link[start+1 : end])
+				if err != nil {
+					return nil, err
+				}
+				u = next.String()
+			}
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"io"
+)
+
+// copyBlob makes sure the destination has the blob, mounting it when both
+// repositories are in the same registry and otherwise streaming it across
+func copyBlob(src *Client, srcRepo string, dst *Client, dstRepo string, blob Descriptor) error {
+	exists, err := dst.BlobExists(dstRepo, blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		dst.progress(blob.Digest, "Layer already exists")
+		return nil
+	}
+	if src.Host == dst.Host && srcRepo != dstRepo {
+		mounted, err := dst.MountBlob(dstRepo, blob.Digest, srcRepo)
+		if err != nil {
+			return err
+		}
+		if mounted {
+			dst.progress(blob.Digest, "Mounted from "+srcRepo)
+			return nil
+		}
+	}
+	dst.progress(blob.Digest, "Copying")
+	err = dst.UploadBlob(dstRepo, blob.Digest, blob.Size, func() (io.ReadCloser, error) {
+		body, _, err := src.GetBlob(srcRepo, blob.Digest)
+		return body, err
+	})
+	if err != nil {
+		return err
+	}
+	dst.progress(blob.Digest, "Copied")
+	return nil
+}
+
+// copyManifest copies the manifest and everything it refers to, without
+// tagging it
+func copyManifest(src *Client, srcRepo string, dst *Client, dstRepo string, raw *RawManifest) error {
+	m, err := raw.Parse()
+	if err != nil {
+		return err
+	}
+	for _, child := range m.Manifests {
+		childRaw, err := src.GetManifest(srcRepo, child.Digest)
+		if err != nil {
+			return err
+		}
+		if err := copyManifest(src, srcRepo, dst, dstRepo, childRaw); err != nil {
+			return err
+		}
+		if err := dst.PutManifest(dstRepo, child.Digest, childRaw); err != nil {
+			return err
+		}
+	}
+	for _, blob := range m.Blobs() {
+		if err := copyBlob(src, srcRepo, dst, dstRepo, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copy copies the image with the tag or digest from one repository to
+// another, which may be in a different registry, under each of the tags.
+// Every platform of a manifest list is copied. It returns the digest, which
+// is the same in both.
+func Copy(src *Client, srcRepo, reference string, dst *Client, dstRepo string, tags []string) (string, error) {
+	raw, err := src.GetManifest(srcRepo, reference)
+	if err != nil {
+		return "", err
+	}
+	if err := copyManifest(src, srcRepo, dst, dstRepo, raw); err != nil {
+		return "", err
+	}
+	if len(tags) == 0 {
+		tags = []string{raw.Digest}
+	}
+	for _, tag := range tags {
+		if err := dst.PutManifest(dstRepo, tag, raw); err != nil {
+			return "", err
+		}
+	}
+	return raw.Digest, nil
+}
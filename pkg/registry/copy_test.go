@@ -0,0 +1,92 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/isotoma/k8ecr/pkg/registry"
+	"github.com/isotoma/k8ecr/pkg/registry/registrytest"
+)
+
+// seedImage stores a manifest list of two platforms in the repository
+func seedImage(server *registrytest.Registry, repo string) registry.RawManifest {
+	list := registry.Manifest{SchemaVersion: 2, MediaType: registry.MediaTypeManifestList}
+	for _, arch := range []string{"amd64", "arm64"} {
+		config := registry.Descriptor{MediaType: registry.MediaTypeConfig, Digest: server.PutBlob(repo, []byte(arch))}
+		layer := registry.Descriptor{MediaType: registry.MediaTypeLayer, Digest: server.PutBlob(repo, []byte("layer "+arch))}
+		body, _ := json.Marshal(&registry.Manifest{
+			SchemaVersion: 2,
+			MediaType:     registry.MediaTypeManifest,
+			Config:        &config,
+			Layers:        []registry.Descriptor{layer},
+		})
+		m := registry.RawManifest{MediaType: registry.MediaTypeManifest, Digest: registry.Digest(body), Body: body}
+		server.PutManifest(repo, m)
+		list.Manifests = append(list.Manifests, registry.Descriptor{
+			MediaType: registry.MediaTypeManifest,
+			Digest:    m.Digest,
+			Size:      int64(len(body)),
+			Platform:  &registry.Platform{Architecture: arch, OS: "linux"},
+		})
+	}
+	body, _ := json.Marshal(&list)
+	m := registry.RawManifest{MediaType: registry.MediaTypeManifestList, Digest: registry.Digest(body), Body: body}
+	server.PutManifest(repo, m, "1.0.0")
+	return m
+}
+
+func TestCopy(T *testing.T) {
+	src := registrytest.New("AWS", "dev")
+	defer src.Close()
+	dst := registrytest.New("AWS", "prod")
+	defer dst.Close()
+	list := seedImage(src, "app")
+
+	digest, err := registry.Copy(src.Client(), "app", "1.0.0", dst.Client(), "app", []string{"1.0.0", "stable"})
+	if err != nil {
+		T.Fatal(err)
+	}
+	if digest != list.Digest {
+		T.Errorf("Expected digest %s, got %s", list.Digest, digest)
+	}
+	if dst.Uploads != 4 {
+		T.Errorf("Expected both platforms to be copied, got %d uploads", dst.Uploads)
+	}
+	for _, tag := range []string{"1.0.0", "stable"} {
+		if m, ok := dst.Manifest("app", tag); !ok || m.Digest != list.Digest {
+			T.Errorf("Expected %s to be tagged", tag)
+		}
+	}
+
+	// Within a registry blobs are mounted rather than copied
+	client := src.Client()
+	if _, err := registry.Copy(client, "app", list.Digest, client, "prod/app", []string{"1.0.0"}); err != nil {
+		T.Fatal(err)
+	}
+	if src.Uploads != 0 {
+		T.Errorf("Expected blobs to be mounted, got %d uploads", src.Uploads)
+	}
+	if _, err := registry.Copy(client, "app", "missing", client, "prod/app", nil); err == nil {
+		T.Error("Expected an error for a missing tag")
+	}
+}
+
+func TestCopyBearer(T *testing.T) {
+	src := registrytest.New("", "")
+	src.Bearer = true
+	defer src.Close()
+	dst := registrytest.New("AWS", "prod")
+	defer dst.Close()
+	list := seedImage(src, "library/redis")
+
+	digest, err := registry.Copy(src.Client(), "library/redis", "1.0.0", dst.Client(), "mirror/redis", []string{"1.0.0"})
+	if err != nil {
+		T.Fatal(err)
+	}
+	if digest != list.Digest {
+		T.Errorf("Expected digest %s, got %s", list.Digest, digest)
+	}
+	if m, ok := dst.Manifest("mirror/redis", "1.0.0"); !ok || m.Digest != list.Digest {
+		T.Error("Expected the image to be copied from a registry using bearer tokens")
+	}
+}
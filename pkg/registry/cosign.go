@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+// Cosign media types and annotations
+const (
+	MediaTypeSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	SignatureAnnotation    = "dev.cosignproject.cosign/signature"
+	simpleSigningType      = "cosign container image signature"
+)
+
+// maxPayload limits how much of a signature payload is read
+const maxPayload = 1 << 20
+
+// SignatureError is returned when an image is not signed, or none of its
+// signatures are valid
+type SignatureError struct {
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	return e.Reason
+}
+
+// ErrUnsigned is returned when an image has no signatures
+var ErrUnsigned = &SignatureError{"not signed"}
+
+// SignatureTag returns the tag cosign stores the signatures of the image
+// with the digest under
+func SignatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
+
+// ParsePublicKeys reads the PEM encoded ECDSA and RSA public keys in data
+func ParsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	keys := make([]crypto.PublicKey, 0)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey:
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("Unsupported public key type %T", key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("No public keys found")
+	}
+	return keys, nil
+}
+
+// verifySignature checks the signature of the payload against the key
+func verifySignature(key crypto.PublicKey, payload, signature []byte) bool {
+	sum := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) > 0 {
+			return false
+		}
+		return ecdsa.Verify(k, sum[:], sig.R, sig.S)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], signature) == nil
+	}
+	return false
+}
+
+// simpleSigning is the payload cosign signs
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// readPayload fetches a signature payload
+func (c *Client) readPayload(repo string, layer Descriptor) ([]byte, error) {
+	blob, _, err := c.GetBlob(repo, layer.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	return ioutil.ReadAll(io.LimitReader(blob, maxPayload))
+}
+
+// VerifySignature checks that the image with the digest has a cosign
+// signature, made with one of the keys, of a payload naming that digest.
+// It returns a SignatureError if it has none, or none are valid.
+func (c *Client) VerifySignature(repo, digest string, keys []crypto.PublicKey) error {
+	raw, err := c.GetManifest(repo, SignatureTag(digest))
+	if e, ok := err.(*Error); ok && e.Status == http.StatusNotFound {
+		return ErrUnsigned
+	}
+	if err != nil {
+		return err
+	}
+	m, err := raw.Parse()
+	if err != nil {
+		return err
+	}
+	problems := make([]string, 0)
+	for _, layer := range m.Layers {
+		encoded, ok := layer.Annotations[SignatureAnnotation]
+		if layer.MediaType != MediaTypeSimpleSigning || !ok {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			problems = append(problems, "signature is not base64")
+			continue
+		}
+		payload, err := c.readPayload(repo, layer)
+		if err != nil {
+			return err
+		}
+		if Digest(payload) != layer.Digest {
+			problems = append(problems, "payload does not match its digest")
+			continue
+		}
+		verified := false
+		for _, key := range keys {
+			if verifySignature(key, payload, signature) {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			problems = append(problems, "signature does not match any key")
+			continue
+		}
+		var signed simpleSigning
+		if err := json.Unmarshal(payload, &signed); err != nil {
+			problems = append(problems, "payload is not a simple signing payload")
+			continue
+		}
+		if signed.Critical.Type != simpleSigningType {
+			problems = append(problems, fmt.Sprintf("payload has type %q", signed.Critical.Type))
+			continue
+		}
+		if signed.Critical.Image.DockerManifestDigest != digest {
+			problems = append(problems, fmt.Sprintf("payload signs %s", signed.Critical.Image.DockerManifestDigest))
+			continue
+		}
+		return nil
+	}
+	if len(problems) == 0 {
+		return ErrUnsigned
+	}
+	return &SignatureError{"no valid signature: " + strings.Join(problems, ", ")}
+}
@@ -0,0 +1,93 @@
+package registry_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/isotoma/k8ecr/pkg/registry"
+	"github.com/isotoma/k8ecr/pkg/registry/registrytest"
+)
+
+// sign stores a cosign signature of the digest, made with the key, as
+// cosign sign would
+func sign(T *testing.T, server *registrytest.Registry, repo, digest, signs string, key *ecdsa.PrivateKey) {
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s/%s"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, server.Host(), repo, signs))
+	sum := sha256.Sum256(payload)
+	signature, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		T.Fatal(err)
+	}
+	config := registry.Descriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: server.PutBlob(repo, []byte("{}")), Size: 2}
+	layer := registry.Descriptor{
+		MediaType:   registry.MediaTypeSimpleSigning,
+		Digest:      server.PutBlob(repo, payload),
+		Size:        int64(len(payload)),
+		Annotations: map[string]string{registry.SignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+	}
+	body, _ := json.Marshal(&registry.Manifest{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeOCIManifest,
+		Config:        &config,
+		Layers:        []registry.Descriptor{layer},
+	})
+	m := registry.RawManifest{MediaType: registry.MediaTypeOCIManifest, Digest: registry.Digest(body), Body: body}
+	server.PutManifest(repo, m, registry.SignatureTag(digest))
+}
+
+func publicKey(T *testing.T, key *ecdsa.PrivateKey) []crypto.PublicKey {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		T.Fatal(err)
+	}
+	keys, err := registry.ParsePublicKeys(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		T.Fatal(err)
+	}
+	return keys
+}
+
+func TestVerifySignature(T *testing.T) {
+	server := registrytest.New("AWS", "secret")
+	defer server.Close()
+	client := server.Client()
+	signer, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keys := publicKey(T, signer)
+
+	signed := seedImage(server, "signed")
+	sign(T, server, "signed", signed.Digest, signed.Digest, signer)
+	if err := client.VerifySignature("signed", signed.Digest, keys); err != nil {
+		T.Errorf("Expected the signature to verify, got %v", err)
+	}
+	if err := client.VerifySignature("signed", signed.Digest, publicKey(T, other)); err == nil {
+		T.Error("Expected a signature made with another key to fail")
+	}
+
+	unsigned := seedImage(server, "unsigned")
+	if err := client.VerifySignature("unsigned", unsigned.Digest, keys); err != registry.ErrUnsigned {
+		T.Errorf("Expected an unsigned image to be reported, got %v", err)
+	}
+
+	// A valid signature of a different image, copied to this one's tag
+	forged := seedImage(server, "forged")
+	sign(T, server, "forged", forged.Digest, registry.Digest([]byte("another image")), signer)
+	if err := client.VerifySignature("forged", forged.Digest, keys); err == nil || !strings.Contains(err.Error(), "payload signs") {
+		T.Errorf("Expected a signature of another image to fail, got %v", err)
+	}
+}
+
+func TestParsePublicKeys(T *testing.T) {
+	if _, err := registry.ParsePublicKeys([]byte("not a key")); err == nil {
+		T.Error("Expected a file without keys to fail")
+	}
+}
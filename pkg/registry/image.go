@@ -0,0 +1,355 @@
+package registry
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Blob is a file holding a config or layer of an image
+type Blob struct {
+	Path string
+	Size int64
+}
+
+// Image is an image read from disk, ready to push
+type Image struct {
+	Manifest RawManifest
+	Children []*Image // the images of a manifest list
+	Blobs    map[string]Blob
+	tmp      string
+}
+
+// Open reads an image from oci:DIR[:REF], an OCI image layout, or from
+// docker-archive:FILE, a tar written by docker save
+func Open(source string) (*Image, error) {
+	if strings.HasPrefix(source, "oci:") {
+		dir, ref := source[len("oci:"):], ""
+		if i := strings.LastIndex(dir, ":"); i > strings.LastIndex(dir, "/") {
+			dir, ref = dir[:i], dir[i+1:]
+		}
+		return openOCI(dir, ref)
+	}
+	if strings.HasPrefix(source, "docker-archive:") {
+		return openArchive(source[len("docker-archive:"):])
+	}
+	return nil, fmt.Errorf("Unknown image source %s, expected oci:DIR[:REF] or docker-archive:FILE", source)
+}
+
+// Close removes any files extracted to read the image
+func (i *Image) Close() error {
+	if i.tmp == "" {
+		return nil
+	}
+	return os.RemoveAll(i.tmp)
+}
+
+func blobPath(dir, digest string) (string, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || strings.ContainsAny(parts[1], `/\.`) {
+		return "", fmt.Errorf("Invalid digest %s", digest)
+	}
+	return filepath.Join(dir, "blobs", parts[0], parts[1]), nil
+}
+
+func openOCI(dir, ref string) (*Image, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+	index := Manifest{}
+	if err := json.Unmarshal(b, &index); err != nil {
+		return nil, err
+	}
+	for _, desc := range index.Manifests {
+		if ref == "" && len(index.Manifests) == 1 || ref != "" && desc.Annotations["org.opencontainers.image.ref.name"] == ref {
+			return ociImage(dir, desc)
+		}
+	}
+	if ref == "" {
+		return nil, fmt.Errorf("%s holds %d images, use oci:%s:REF to choose one", dir, len(index.Manifests), dir)
+	}
+	return nil, fmt.Errorf("%s has no image %s", dir, ref)
+}
+
+func ociImage(dir string, desc Descriptor) (*Image, error) {
+	path, err := blobPath(dir, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if Digest(body) != desc.Digest {
+		return nil, fmt.Errorf("Manifest %s does not match its digest", path)
+	}
+	img := &Image{
+		Manifest: RawManifest{MediaType: desc.MediaType, Digest: desc.Digest, Body: body},
+		Blobs:    make(map[string]Blob),
+	}
+	m, err := img.Manifest.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if img.Manifest.MediaType == "" {
+		img.Manifest.MediaType = m.MediaType
+	}
+	if m.IsList() {
+		for _, child := range m.Manifests {
+			c, err := ociImage(dir, child)
+			if err != nil {
+				return nil, err
+			}
+			img.Children = append(img.Children, c)
+		}
+		return img, nil
+	}
+	for _, blob := range m.Blobs() {
+		path, err := blobPath(dir, blob.Digest)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		img.Blobs[blob.Digest] = Blob{Path: path, Size: info.Size()}
+	}
+	return img, nil
+}
+
+// inside joins the name to dir, refusing names outside it
+func inside(dir, name string) (string, error) {
+	clean := filepath.Clean(name)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the archive", name)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// extract the tar to dir. Links are resolved within the archive, so a layer
+// shared between images may be stored once and linked to.
+func extract(file, dir string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := tar.NewReader(f)
+	links := make(map[string]string)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		target, err := inside(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeSymlink, tar.TypeLink:
+			name := header.Linkname
+			if header.Typeflag == tar.TypeSymlink && !path.IsAbs(name) {
+				name = path.Join(path.Dir(header.Name), name)
+			}
+			if links[target], err = inside(dir, name); err != nil {
+				return fmt.Errorf("%s links to %s: %s", header.Name, header.Linkname, err)
+			}
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+		default:
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, reader)
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	// Links may point to other links, so copy those whose target exists
+	// until none are left
+	for len(links) > 0 {
+		copied := false
+		for target, source := range links {
+			if _, pending := links[source]; pending {
+				continue
+			}
+			if err := copyFile(source, target); err != nil {
+				return fmt.Errorf("%s links to a file not in the archive: %s", target[len(dir)+1:], err)
+			}
+			delete(links, target)
+			copied = true
+		}
+		if !copied {
+			return errors.New("Links in the archive form a loop")
+		}
+	}
+	return nil
+}
+
+// copyFile copies the regular file at source to target
+func copyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a file", filepath.Base(source))
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// fileDigest returns the digest and size of the file
+func fileDigest(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// compressedLayer returns the layer gzipped, compressing it if it is not already
+func compressedLayer(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	magic, err := reader.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return path, nil
+	}
+	target := path + ".gz"
+	out, err := os.Create(target)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	writer := gzip.NewWriter(out)
+	if _, err := io.Copy(writer, reader); err != nil {
+		return "", err
+	}
+	return target, writer.Close()
+}
+
+func openArchive(file string) (*Image, error) {
+	tmp, err := ioutil.TempDir("", "k8ecr")
+	if err != nil {
+		return nil, err
+	}
+	img := &Image{Blobs: make(map[string]Blob), tmp: tmp}
+	if err := img.readArchive(file); err != nil {
+		img.Close()
+		return nil, err
+	}
+	return img, nil
+}
+
+func (i *Image) readArchive(file string) error {
+	if err := extract(file, i.tmp); err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(i.tmp, "manifest.json"))
+	if err != nil {
+		return err
+	}
+	entries := []struct {
+		Config string
+		Layers []string
+	}{}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+	if len(entries) != 1 {
+		return fmt.Errorf("%s holds %d images, expected one", file, len(entries))
+	}
+	blob := func(name, mediaType string) (Descriptor, error) {
+		path, err := inside(i.tmp, name)
+		if err != nil {
+			return Descriptor{}, err
+		}
+		digest, size, err := fileDigest(path)
+		if err != nil {
+			return Descriptor{}, err
+		}
+		i.Blobs[digest] = Blob{Path: path, Size: size}
+		return Descriptor{MediaType: mediaType, Size: size, Digest: digest}, nil
+	}
+	if entries[0].Config == "" {
+		return errors.New("No image config in " + file)
+	}
+	config, err := blob(entries[0].Config, MediaTypeConfig)
+	if err != nil {
+		return err
+	}
+	m := Manifest{SchemaVersion: 2, MediaType: MediaTypeManifest, Config: &config}
+	for _, layer := range entries[0].Layers {
+		path, err := inside(i.tmp, layer)
+		if err != nil {
+			return err
+		}
+		if path, err = compressedLayer(path); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(i.tmp, path)
+		if err != nil {
+			return err
+		}
+		desc, err := blob(rel, MediaTypeLayer)
+		if err != nil {
+			return err
+		}
+		m.Layers = append(m.Layers, desc)
+	}
+	body, err := json.Marshal(&m)
+	if err != nil {
+		return err
+	}
+	i.Manifest = RawManifest{MediaType: MediaTypeManifest, Digest: Digest(body), Body: body}
+	return nil
+}
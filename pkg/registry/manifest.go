@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"encoding/json"
+)
+
+// Media types of manifests, configs and layers
+const (
+	MediaTypeManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeConfig       = "application/vnd.docker.container.image.v1+json"
+	MediaTypeLayer        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	MediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+)
+
+var manifestTypes = []string{
+	MediaTypeManifest,
+	MediaTypeManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeOCIIndex,
+}
+
+// Platform an image in a manifest list runs on
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Descriptor refers to a blob or manifest by digest
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Size        int64             `json:"size"`
+	Digest      string            `json:"digest"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is an image manifest, or a manifest list or index of manifests
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        *Descriptor       `json:"config,omitempty"`
+	Layers        []Descriptor      `json:"layers,omitempty"`
+	Manifests     []Descriptor      `json:"manifests,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// IsList returns whether this is a manifest list or index
+func (m *Manifest) IsList() bool {
+	return m.MediaType == MediaTypeManifestList || m.MediaType == MediaTypeOCIIndex || (m.Config == nil && len(m.Manifests) > 0)
+}
+
+// Blobs returns the config and layers of an image manifest
+func (m *Manifest) Blobs() []Descriptor {
+	blobs := make([]Descriptor, 0, len(m.Layers)+1)
+	if m.Config != nil {
+		blobs = append(blobs, *m.Config)
+	}
+	return append(blobs, m.Layers...)
+}
+
+// RawManifest is a manifest exactly as stored, since its digest is of these bytes
+type RawManifest struct {
+	MediaType string
+	Digest    string
+	Body      []byte
+}
+
+// Parse the manifest
+func (r *RawManifest) Parse() (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(r.Body, m); err != nil {
+		return nil, err
+	}
+	if m.MediaType == "" {
+		m.MediaType = r.MediaType
+	}
+	return m, nil
+}
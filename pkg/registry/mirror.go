@@ -0,0 +1,43 @@
+package registry
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// DockerHub is the registry that images named without a host come from
+const DockerHub = "registry-1.docker.io"
+
+// Normalize returns the registry host and repository an image name refers
+// to, expanding Docker Hub names as Docker does
+func Normalize(host, repo string) (string, string) {
+	if host == "" || host == "docker.io" || host == "index.docker.io" {
+		host = DockerHub
+	}
+	if host == DockerHub && !strings.Contains(repo, "/") {
+		repo = "library/" + repo
+	}
+	return host, repo
+}
+
+// MissingTags returns the tags matching the glob pattern that are not
+// among existing, in order
+func MissingTags(tags, existing []string, pattern string) ([]string, error) {
+	have := make(map[string]bool)
+	for _, t := range existing {
+		have[t] = true
+	}
+	missing := make([]string, 0)
+	for _, t := range tags {
+		matched, err := path.Match(pattern, t)
+		if err != nil {
+			return nil, err
+		}
+		if matched && !have[t] {
+			missing = append(missing, t)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
@@ -0,0 +1,43 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(T *testing.T) {
+	tests := []struct{ host, repo, expectedHost, expectedRepo string }{
+		{"", "redis", DockerHub, "library/redis"},
+		{"docker.io", "bitnami/redis", DockerHub, "bitnami/redis"},
+		{"quay.io", "coreos/etcd", "quay.io", "coreos/etcd"},
+	}
+	for _, t := range tests {
+		host, repo := Normalize(t.host, t.repo)
+		if host != t.expectedHost || repo != t.expectedRepo {
+			T.Errorf("Expected %s/%s, got %s/%s", t.expectedHost, t.expectedRepo, host, repo)
+		}
+	}
+}
+
+func TestMissingTags(T *testing.T) {
+	missing, err := MissingTags([]string{"7.2", "7.0", "6.2", "7.0-alpine", "latest"}, []string{"7.0"}, "7.*")
+	if err != nil {
+		T.Fatal(err)
+	}
+	if strings.Join(missing, " ") != "7.0-alpine 7.2" {
+		T.Errorf("Unexpected tags %v", missing)
+	}
+	if _, err := MissingTags([]string{"1"}, nil, "["); err == nil {
+		T.Error("Expected an error for a bad pattern")
+	}
+}
+
+func TestParseChallenge(T *testing.T) {
+	c := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/redis:pull"`)
+	if c.scheme != "bearer" || c.params["realm"] != "https://auth.docker.io/token" || c.params["service"] != "registry.docker.io" {
+		T.Errorf("Unexpected challenge %v", c)
+	}
+	if parseChallenge("") != nil {
+		T.Error("Expected no challenge")
+	}
+}
@@ -0,0 +1,54 @@
+package registry
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// maxConfig limits how much of an image config is read
+const maxConfig = 4 << 20
+
+// String returns the platform as os/arch, or os/arch/variant
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Platforms returns the platforms of the image: those of each manifest of
+// a manifest list, or that of a single image's config
+func (c *Client) Platforms(repo, reference string) ([]Platform, error) {
+	raw, err := c.GetManifest(repo, reference)
+	if err != nil {
+		return nil, err
+	}
+	m, err := raw.Parse()
+	if err != nil {
+		return nil, err
+	}
+	platforms := make([]Platform, 0)
+	if m.IsList() {
+		for _, d := range m.Manifests {
+			// Attestations are listed with an unknown platform
+			if d.Platform != nil && d.Platform.OS != "unknown" {
+				platforms = append(platforms, *d.Platform)
+			}
+		}
+		return platforms, nil
+	}
+	if m.Config == nil {
+		return platforms, nil
+	}
+	blob, _, err := c.GetBlob(repo, m.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	var config Platform
+	if err := json.NewDecoder(io.LimitReader(blob, maxConfig)).Decode(&config); err != nil {
+		return nil, err
+	}
+	return append(platforms, config), nil
+}
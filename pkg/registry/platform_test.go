@@ -0,0 +1,40 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/isotoma/k8ecr/pkg/registry"
+	"github.com/isotoma/k8ecr/pkg/registry/registrytest"
+)
+
+func TestPlatforms(T *testing.T) {
+	server := registrytest.New("", "")
+	defer server.Close()
+	client := server.Client()
+
+	seedImage(server, "multi")
+	platforms, err := client.Platforms("multi", "1.0.0")
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(platforms) != 2 || platforms[0].String() != "linux/amd64" || platforms[1].String() != "linux/arm64" {
+		T.Errorf("Unexpected platforms of a manifest list: %v", platforms)
+	}
+
+	config := []byte(`{"architecture": "arm", "os": "linux", "variant": "v7", "rootfs": {}}`)
+	body, _ := json.Marshal(&registry.Manifest{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeManifest,
+		Config:        &registry.Descriptor{MediaType: registry.MediaTypeConfig, Digest: server.PutBlob("single", config)},
+	})
+	server.PutManifest("single", registry.RawManifest{MediaType: registry.MediaTypeManifest, Digest: registry.Digest(body), Body: body}, "1.0.0")
+	platforms, err = client.Platforms("single", "1.0.0")
+	if err != nil {
+		T.Fatal(err)
+	}
+	if !reflect.DeepEqual(platforms, []registry.Platform{{Architecture: "arm", OS: "linux", Variant: "v7"}}) {
+		T.Errorf("Unexpected platform of a single image: %v", platforms)
+	}
+}
@@ -0,0 +1,65 @@
+package registry
+
+import (
+	"io"
+	"os"
+	"sort"
+)
+
+func (c *Client) progress(digest, status string) {
+	if c.Progress != nil {
+		c.Progress(digest, status)
+	}
+}
+
+// pushBlobs uploads the blobs of the image that the repository lacks, and
+// the manifests of any children
+func (c *Client) pushBlobs(repo string, img *Image) error {
+	for _, child := range img.Children {
+		if err := c.pushBlobs(repo, child); err != nil {
+			return err
+		}
+		if err := c.PutManifest(repo, child.Manifest.Digest, &child.Manifest); err != nil {
+			return err
+		}
+	}
+	digests := make([]string, 0, len(img.Blobs))
+	for digest := range img.Blobs {
+		digests = append(digests, digest)
+	}
+	sort.Strings(digests)
+	for _, digest := range digests {
+		exists, err := c.BlobExists(repo, digest)
+		if err != nil {
+			return err
+		}
+		if exists {
+			c.progress(digest, "Layer already exists")
+			continue
+		}
+		blob := img.Blobs[digest]
+		c.progress(digest, "Uploading")
+		err = c.UploadBlob(repo, digest, blob.Size, func() (io.ReadCloser, error) {
+			return os.Open(blob.Path)
+		})
+		if err != nil {
+			return err
+		}
+		c.progress(digest, "Pushed")
+	}
+	return nil
+}
+
+// PushImage uploads the image to the repository under each tag, skipping
+// blobs the repository already has. It returns the manifest digest.
+func (c *Client) PushImage(repo string, img *Image, tags []string) (string, error) {
+	if err := c.pushBlobs(repo, img); err != nil {
+		return "", err
+	}
+	for _, tag := range tags {
+		if err := c.PutManifest(repo, tag, &img.Manifest); err != nil {
+			return "", err
+		}
+	}
+	return img.Manifest.Digest, nil
+}
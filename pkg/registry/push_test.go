@@ -0,0 +1,193 @@
+package registry_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/isotoma/k8ecr/pkg/registry"
+	"github.com/isotoma/k8ecr/pkg/registry/registrytest"
+)
+
+func writeBlob(T *testing.T, dir string, content []byte) registry.Descriptor {
+	digest := registry.Digest(content)
+	path := filepath.Join(dir, "blobs", "sha256", strings.TrimPrefix(digest, "sha256:"))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		T.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		T.Fatal(err)
+	}
+	return registry.Descriptor{Digest: digest, Size: int64(len(content))}
+}
+
+func writeLayout(T *testing.T) string {
+	dir, err := ioutil.TempDir("", "layout")
+	if err != nil {
+		T.Fatal(err)
+	}
+	config := writeBlob(T, dir, []byte(`{"architecture": "amd64", "os": "linux"}`))
+	config.MediaType = "application/vnd.oci.image.config.v1+json"
+	layer := writeBlob(T, dir, []byte("layer"))
+	layer.MediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+	manifest, _ := json.Marshal(&registry.Manifest{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeOCIManifest,
+		Config:        &config,
+		Layers:        []registry.Descriptor{layer},
+	})
+	desc := writeBlob(T, dir, manifest)
+	desc.MediaType = registry.MediaTypeOCIManifest
+	desc.Annotations = map[string]string{"org.opencontainers.image.ref.name": "latest"}
+	index, _ := json.Marshal(&registry.Manifest{SchemaVersion: 2, Manifests: []registry.Descriptor{desc}})
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.json"), index, 0600); err != nil {
+		T.Fatal(err)
+	}
+	return dir
+}
+
+func TestPushOCI(T *testing.T) {
+	dir := writeLayout(T)
+	defer os.RemoveAll(dir)
+	server := registrytest.New("AWS", "secret")
+	defer server.Close()
+
+	img, err := registry.Open("oci:" + dir + ":latest")
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer img.Close()
+	client := server.Client()
+	digest, err := client.PushImage("app", img, []string{"1.0.0"})
+	if err != nil {
+		T.Fatal(err)
+	}
+	if server.Uploads != 2 {
+		T.Errorf("Expected 2 uploads, got %d", server.Uploads)
+	}
+	m, ok := server.Manifest("app", "1.0.0")
+	if !ok || m.Digest != digest || m.MediaType != registry.MediaTypeOCIManifest {
+		T.Errorf("Unexpected manifest %v", m)
+	}
+
+	if _, err := client.PushImage("app", img, []string{"1.0.1", "1"}); err != nil {
+		T.Fatal(err)
+	}
+	if server.Uploads != 2 {
+		T.Errorf("Expected existing blobs to be skipped, got %d uploads", server.Uploads)
+	}
+	tags, err := client.Tags("app")
+	if err != nil {
+		T.Fatal(err)
+	}
+	if strings.Join(tags, " ") != "1 1.0.0 1.0.1" {
+		T.Errorf("Unexpected tags %v", tags)
+	}
+
+	if _, err := registry.Open("oci:" + dir + ":missing"); err == nil {
+		T.Error("Expected an error for a missing reference")
+	}
+	bad := registrytest.New("AWS", "secret")
+	defer bad.Close()
+	client = bad.Client()
+	client.Password = "wrong"
+	if _, err := client.PushImage("app", img, []string{"1.0.0"}); err == nil {
+		T.Error("Expected an error with the wrong password")
+	}
+}
+
+// archiveEntry is a file in a test archive, or a symlink if link is set
+type archiveEntry struct{ name, content, link string }
+
+func writeArchive(T *testing.T, entries ...archiveEntry) string {
+	file, err := ioutil.TempFile("", "archive")
+	if err != nil {
+		T.Fatal(err)
+	}
+	archive := tar.NewWriter(file)
+	for _, f := range entries {
+		if f.link != "" {
+			archive.WriteHeader(&tar.Header{Name: f.name, Mode: 0777, Linkname: f.link, Typeflag: tar.TypeSymlink})
+			continue
+		}
+		archive.WriteHeader(&tar.Header{Name: f.name, Mode: 0600, Size: int64(len(f.content)), Typeflag: tar.TypeReg})
+		archive.Write([]byte(f.content))
+	}
+	archive.Close()
+	file.Close()
+	return file.Name()
+}
+
+func TestPushDockerArchive(T *testing.T) {
+	file := writeArchive(T,
+		archiveEntry{name: "manifest.json", content: `[{"Config": "abc.json", "RepoTags": ["app:latest"], "Layers": ["def/layer.tar"]}]`},
+		archiveEntry{name: "abc.json", content: `{"architecture": "amd64", "os": "linux"}`},
+		archiveEntry{name: "def/layer.tar", content: "uncompressed layer"},
+	)
+	defer os.Remove(file)
+
+	img, err := registry.Open("docker-archive:" + file)
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer img.Close()
+	server := registrytest.New("", "")
+	defer server.Close()
+	if _, err := server.Client().PushImage("app", img, []string{"latest"}); err != nil {
+		T.Fatal(err)
+	}
+	raw, _ := server.Manifest("app", "latest")
+	m, err := raw.Parse()
+	if err != nil {
+		T.Fatal(err)
+	}
+	if m.MediaType != registry.MediaTypeManifest || len(m.Layers) != 1 || m.Layers[0].MediaType != registry.MediaTypeLayer {
+		T.Fatalf("Unexpected manifest %s", raw.Body)
+	}
+	layer, ok := server.Blob("app", m.Layers[0].Digest)
+	if !ok || !bytes.HasPrefix(layer, []byte{0x1f, 0x8b}) {
+		T.Error("Expected the layer to be uploaded gzipped")
+	}
+}
+
+func TestDockerArchiveSymlinks(T *testing.T) {
+	// docker save stores a layer shared by several images once, and links
+	// the others to it
+	file := writeArchive(T,
+		archiveEntry{name: "manifest.json", content: `[{"Config": "abc.json", "Layers": ["def/layer.tar", "ghi/layer.tar"]}]`},
+		archiveEntry{name: "abc.json", content: `{"architecture": "amd64", "os": "linux"}`},
+		archiveEntry{name: "ghi/layer.tar", link: "../def/layer.tar"},
+		archiveEntry{name: "def/layer.tar", content: "shared layer"},
+	)
+	defer os.Remove(file)
+	img, err := registry.Open("docker-archive:" + file)
+	if err != nil {
+		T.Fatal(err)
+	}
+	defer img.Close()
+	m, err := img.Manifest.Parse()
+	if err != nil {
+		T.Fatal(err)
+	}
+	if len(m.Layers) != 2 || m.Layers[0].Digest != m.Layers[1].Digest {
+		T.Errorf("Expected the linked layer to be the same as the one it links to: %s", img.Manifest.Body)
+	}
+
+	for _, link := range []string{"../../etc/passwd", "/etc/passwd", "missing/layer.tar"} {
+		file := writeArchive(T,
+			archiveEntry{name: "manifest.json", content: `[{"Config": "abc.json", "Layers": ["ghi/layer.tar"]}]`},
+			archiveEntry{name: "abc.json", content: `{"architecture": "amd64", "os": "linux"}`},
+			archiveEntry{name: "ghi/layer.tar", link: link},
+		)
+		if img, err := registry.Open("docker-archive:" + file); err == nil {
+			img.Close()
+			T.Errorf("Expected a link to %s to be refused", link)
+		}
+		os.Remove(file)
+	}
+}
@@ -0,0 +1,272 @@
+// Package registrytest provides an in-memory registry for testing
+package registrytest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/isotoma/k8ecr/pkg/registry"
+)
+
+// Registry is an in-memory registry implementing enough of the Docker
+// Registry HTTP API V2 to push and pull images
+type Registry struct {
+	*httptest.Server
+	Username string
+	Password string
+	// Bearer makes the registry ask for a token, as Docker Hub does
+	Bearer bool
+	// Uploads counts the blobs uploaded, rather than found or mounted
+	Uploads int
+
+	mu        sync.Mutex
+	blobs     map[string]map[string][]byte
+	manifests map[string]map[string]registry.RawManifest
+	uploads   map[string][]byte
+	next      int
+}
+
+// New starts a registry that requires the username and password, if given
+func New(username, password string) *Registry {
+	r := &Registry{
+		Username:  username,
+		Password:  password,
+		blobs:     make(map[string]map[string][]byte),
+		manifests: make(map[string]map[string]registry.RawManifest),
+		uploads:   make(map[string][]byte),
+	}
+	r.Server = httptest.NewServer(r)
+	return r
+}
+
+// Host is the host and port of the registry
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+// Client returns a client for the registry
+func (r *Registry) Client() *registry.Client {
+	c := registry.New(r.Host(), r.Username, r.Password)
+	c.Scheme = "http"
+	return c
+}
+
+// PutBlob stores a blob in the repository, returning its digest
+func (r *Registry) PutBlob(repo string, content []byte) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digest := registry.Digest(content)
+	if r.blobs[repo] == nil {
+		r.blobs[repo] = make(map[string][]byte)
+	}
+	r.blobs[repo][digest] = content
+	return digest
+}
+
+// PutManifest stores a manifest in the repository under its digest and the tags
+func (r *Registry) PutManifest(repo string, m registry.RawManifest, tags ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.manifests[repo] == nil {
+		r.manifests[repo] = make(map[string]registry.RawManifest)
+	}
+	r.manifests[repo][m.Digest] = m
+	for _, t := range tags {
+		r.manifests[repo][t] = m
+	}
+}
+
+// Blob returns the blob, if the repository has it
+func (r *Registry) Blob(repo, digest string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.blobs[repo][digest]
+	return b, ok
+}
+
+// Manifest returns the manifest with the tag or digest, if the repository has it
+func (r *Registry) Manifest(repo, reference string) (registry.RawManifest, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.manifests[repo][reference]
+	return m, ok
+}
+
+func (r *Registry) token(service string) string {
+	return "token-for-" + service
+}
+
+func errorResponse(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"errors": [{"code": %q, "message": %q}]}`, code, strings.ToLower(code))
+}
+
+// split finds the repository and the rest of the path after the keyword
+func split(path, keyword string) (string, string, bool) {
+	i := strings.Index(path, keyword)
+	if i < 0 {
+		return "", "", false
+	}
+	return path[:i], path[i+len(keyword):], true
+}
+
+// ServeHTTP handles requests to the registry
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.Bearer {
+		if req.URL.Path == "/token" {
+			fmt.Fprintf(w, `{"token": %q}`, r.token(req.URL.Query().Get("service")))
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer "+r.token("registrytest") {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registrytest"`, r.URL))
+			errorResponse(w, http.StatusUnauthorized, "UNAUTHORIZED")
+			return
+		}
+	} else if r.Username != "" {
+		if user, password, ok := req.BasicAuth(); !ok || user != r.Username || password != r.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registrytest"`)
+			errorResponse(w, http.StatusUnauthorized, "UNAUTHORIZED")
+			return
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if path == "" {
+		return
+	}
+	if repo, id, ok := split(path, "/blobs/uploads/"); ok {
+		r.upload(w, req, repo, id)
+	} else if repo, digest, ok := split(path, "/blobs/"); ok {
+		b, ok := r.blobs[repo][digest]
+		if !ok {
+			errorResponse(w, http.StatusNotFound, "BLOB_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(b)))
+		w.Header().Set("Docker-Content-Digest", digest)
+		if req.Method == "GET" {
+			w.Write(b)
+		}
+	} else if repo, reference, ok := split(path, "/manifests/"); ok {
+		r.manifest(w, req, repo, reference)
+	} else if repo, _, ok := split(path, "/tags/list"); ok {
+		tags := make([]string, 0)
+		for t := range r.manifests[repo] {
+			if !strings.HasPrefix(t, "sha256:") {
+				tags = append(tags, t)
+			}
+		}
+		sort.Strings(tags)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name": %q, "tags": [%s]}`, repo, quoteAll(tags))
+	} else {
+		errorResponse(w, http.StatusNotFound, "NAME_UNKNOWN")
+	}
+}
+
+func quoteAll(s []string) string {
+	q := make([]string, len(s))
+	for i := range s {
+		q[i] = fmt.Sprintf("%q", s[i])
+	}
+	return strings.Join(q, ", ")
+}
+
+func (r *Registry) upload(w http.ResponseWriter, req *http.Request, repo, id string) {
+	switch req.Method {
+	case "POST":
+		if digest, from := req.URL.Query().Get("mount"), req.URL.Query().Get("from"); digest != "" {
+			if b, ok := r.blobs[from][digest]; ok {
+				if r.blobs[repo] == nil {
+					r.blobs[repo] = make(map[string][]byte)
+				}
+				r.blobs[repo][digest] = b
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		r.next++
+		id = fmt.Sprint(r.next)
+		r.uploads[id] = []byte{}
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repo, id))
+		w.WriteHeader(http.StatusAccepted)
+	case "PATCH":
+		b, _ := ioutil.ReadAll(req.Body)
+		r.uploads[id] = append(r.uploads[id], b...)
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repo, id))
+		w.WriteHeader(http.StatusAccepted)
+	case "PUT":
+		b, _ := ioutil.ReadAll(req.Body)
+		content := append(r.uploads[id], b...)
+		delete(r.uploads, id)
+		digest := req.URL.Query().Get("digest")
+		if registry.Digest(content) != digest {
+			errorResponse(w, http.StatusBadRequest, "DIGEST_INVALID")
+			return
+		}
+		if r.blobs[repo] == nil {
+			r.blobs[repo] = make(map[string][]byte)
+		}
+		r.blobs[repo][digest] = content
+		r.Uploads++
+		w.WriteHeader(http.StatusCreated)
+	default:
+		errorResponse(w, http.StatusMethodNotAllowed, "UNSUPPORTED")
+	}
+}
+
+func (r *Registry) manifest(w http.ResponseWriter, req *http.Request, repo, reference string) {
+	switch req.Method {
+	case "GET", "HEAD":
+		m, ok := r.manifests[repo][reference]
+		if !ok {
+			errorResponse(w, http.StatusNotFound, "MANIFEST_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Type", m.MediaType)
+		w.Header().Set("Docker-Content-Digest", m.Digest)
+		if req.Method == "GET" {
+			w.Write(m.Body)
+		}
+	case "PUT":
+		body, _ := ioutil.ReadAll(req.Body)
+		m := registry.RawManifest{MediaType: req.Header.Get("Content-Type"), Digest: registry.Digest(body), Body: body}
+		if strings.HasPrefix(reference, "sha256:") && reference != m.Digest {
+			errorResponse(w, http.StatusBadRequest, "DIGEST_INVALID")
+			return
+		}
+		parsed, err := m.Parse()
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "MANIFEST_INVALID")
+			return
+		}
+		for _, blob := range parsed.Blobs() {
+			if _, ok := r.blobs[repo][blob.Digest]; !ok {
+				errorResponse(w, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN")
+				return
+			}
+		}
+		for _, child := range parsed.Manifests {
+			if _, ok := r.manifests[repo][child.Digest]; !ok {
+				errorResponse(w, http.StatusBadRequest, "MANIFEST_UNKNOWN")
+				return
+			}
+		}
+		if r.manifests[repo] == nil {
+			r.manifests[repo] = make(map[string]registry.RawManifest)
+		}
+		r.manifests[repo][m.Digest] = m
+		r.manifests[repo][reference] = m
+		w.Header().Set("Docker-Content-Digest", m.Digest)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		errorResponse(w, http.StatusMethodNotAllowed, "UNSUPPORTED")
+	}
+}
@@ -26,12 +26,16 @@ var deploymentResource = &apps.ResourceManager{
 		var d appsv1beta1.Deployment
 		d = item.(appsv1beta1.Deployment)
 		allResources := make([]apps.Container, 0)
-		for _, r := range resources(d.Name, d.ObjectMeta, d.Spec.Template.Spec.Containers) {
+		for _, r := range resources(d.Name, d.ObjectMeta, d.Spec.Template.Spec) {
 			allResources = append(allResources, r)
 		}
 		return allResources
 	},
 	Upgrade: func(mgr *apps.AppManager, image *apps.ChangeSet, resource apps.Container) error {
+		ref, err := image.ImageRef(mgr.Pin)
+		if err != nil {
+			return err
+		}
 		client := mgr.ClientSet.AppsV1beta1().Deployments(mgr.Namespace)
 		item, err := client.Get(resource.ContainerID.Resource, metav1.GetOptions{})
 		if err != nil {
@@ -39,8 +43,8 @@ var deploymentResource = &apps.ResourceManager{
 		}
 		for i, container := range item.Spec.Template.Spec.Containers {
 			if container.Name == resource.ContainerID.Container {
-				fmt.Printf("        %s/%s image -> %s\n", resource.ContainerID.Resource, resource.ContainerID.Container, image.RegistryPath())
-				item.Spec.Template.Spec.Containers[i].Image = image.RegistryPath()
+				fmt.Printf("        %s/%s image -> %s\n", resource.ContainerID.Resource, resource.ContainerID.Container, ref)
+				item.Spec.Template.Spec.Containers[i].Image = ref
 			}
 		}
 		_, err = client.Update(item)
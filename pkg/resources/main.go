@@ -1,7 +1,6 @@
 package resources
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/isotoma/k8ecr/pkg/apps"
@@ -9,46 +8,60 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func parse(url string) (*apps.ImageIdentifier, apps.Version) {
-	p1 := strings.Split(url, "/")
-	registry := p1[0]
-	if strings.HasSuffix(registry, "amazonaws.com") {
-		fmt.Printf("Registry %s\n", registry)
-		var p2 []string
-		switch {
-		case len(p1) == 1:
-			p2 = strings.Split(p1[0], ":")
-		case len(p1) == 2:
-			p2 = strings.Split(p1[1], ":")
-		default:
-			panic(fmt.Errorf("Unexpected number of / in image"))
-		}
-		repo := p2[0]
-		version := "latest"
-		if len(p2) == 2 {
-			version = p2[1]
-		}
-		return &apps.ImageIdentifier{
-			Registry: registry,
-			Repo:     repo,
-		}, apps.Version(version)
+// parse an image reference into its identifier, version and digest. Images
+// pinned only by digest report the digest as their version. Images without
+// a registry are from Docker Hub.
+func parse(url string) (*apps.ImageIdentifier, apps.Version, string) {
+	if url == "" {
+		return nil, "", ""
+	}
+	name, digest := url, ""
+	if i := strings.Index(url, "@"); i >= 0 {
+		name, digest = url[:i], url[i+1:]
 	}
-	return nil, ""
+	version := "latest"
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, version = name[:i], name[i+1:]
+	} else if digest != "" {
+		version = digest
+	}
+	registry, repo := "docker.io", name
+	p1 := strings.SplitN(name, "/", 2)
+	switch {
+	case len(p1) == 1:
+		repo = "library/" + name
+	case strings.ContainsAny(p1[0], ".:") || p1[0] == "localhost":
+		registry, repo = p1[0], p1[1]
+	}
+	return &apps.ImageIdentifier{
+		Registry: registry,
+		Repo:     repo,
+	}, apps.Version(version), digest
 }
 
-func resources(name string, meta metav1.ObjectMeta, spec []corev1.Container) []apps.Container {
+func resources(name string, meta metav1.ObjectMeta, pod corev1.PodSpec) []apps.Container {
+	placement := apps.Placement{NodeSelector: pod.NodeSelector}
+	if pod.Affinity != nil && pod.Affinity.NodeAffinity != nil {
+		placement.Required = pod.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	}
 	res := make([]apps.Container, 0)
-	for _, c := range spec {
-		id, version := parse(c.Image)
+	// Images only used by init containers are still in use
+	containers := make([]corev1.Container, 0, len(pod.InitContainers)+len(pod.Containers))
+	containers = append(append(containers, pod.InitContainers...), pod.Containers...)
+	for i, c := range containers {
+		id, version, digest := parse(c.Image)
 		if id != nil {
 			r := apps.Container{
 				ContainerID: apps.ContainerIdentifier{
 					Resource:  name,
 					Container: c.Name,
 				},
-				ImageID: *id,
-				App:     meta.Labels["app"],
-				Current: version,
+				ImageID:   *id,
+				App:       meta.Labels["app"],
+				Current:   version,
+				Digest:    digest,
+				Placement: placement,
+				Init:      i < len(pod.InitContainers),
 			}
 			res = append(res, r)
 		}
@@ -59,4 +72,5 @@ func resources(name string, meta metav1.ObjectMeta, spec []corev1.Container) []a
 func Register() {
 	apps.RegisterResource(deploymentResource)
 	apps.RegisterResource(cronjobResource)
+	apps.RegisterResource(replicasetResource)
 }
@@ -0,0 +1,82 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/isotoma/k8ecr/pkg/apps"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParse(T *testing.T) {
+	registry := "123.dkr.ecr.eu-west-2.amazonaws.com"
+	for image, expected := range map[string]struct {
+		version apps.Version
+		digest  string
+	}{
+		registry + "/repo1":                  {"latest", ""},
+		registry + "/repo1:1.0.0":            {"1.0.0", ""},
+		registry + "/repo1:1.0.0@sha256:abc": {"1.0.0", "sha256:abc"},
+		registry + "/repo1@sha256:abc":       {"sha256:abc", "sha256:abc"},
+	} {
+		id, version, digest := parse(image)
+		if id == nil || id.Registry != registry || id.Repo != "repo1" {
+			T.Errorf("%s parsed to wrong identifier %v", image, id)
+		}
+		if version != expected.version || digest != expected.digest {
+			T.Errorf("%s parsed to %s %s", image, version, digest)
+		}
+	}
+	for image, expected := range map[string]apps.ImageIdentifier{
+		"nginx:1.15":                  {Registry: "docker.io", Repo: "library/nginx"},
+		"bitnami/redis":               {Registry: "docker.io", Repo: "bitnami/redis"},
+		"quay.io/coreos/etcd:v3.3":    {Registry: "quay.io", Repo: "coreos/etcd"},
+		"localhost:5000/app@sha256:a": {Registry: "localhost:5000", Repo: "app"},
+	} {
+		id, _, _ := parse(image)
+		if id == nil || *id != expected || id.IsECR() {
+			T.Errorf("%s parsed to wrong identifier %v", image, id)
+		}
+	}
+}
+
+func TestInitContainers(T *testing.T) {
+	pod := corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "migrate", Image: "reg.example.com/migrate:1.0.0"}},
+		Containers:     []corev1.Container{{Name: "app", Image: "reg.example.com/app:1.0.0"}},
+	}
+	containers := resources("web", metav1.ObjectMeta{}, pod)
+	if len(containers) != 2 || containers[0].ContainerID.Container != "migrate" || containers[0].ImageID.Repo != "migrate" {
+		T.Fatalf("Init containers should be found: %v", containers)
+	}
+	if !containers[0].Init || containers[1].Init {
+		T.Errorf("Only init containers should be marked as such: %v", containers)
+	}
+}
+
+func TestScanInitContainers(T *testing.T) {
+	Register()
+	deployment := &appsv1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}},
+	}
+	deployment.Spec.Template.Spec = corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "migrate", Image: "123.dkr.ecr.eu-west-2.amazonaws.com/migrate:1.0.0"}},
+		Containers:     []corev1.Container{{Name: "app", Image: "123.dkr.ecr.eu-west-2.amazonaws.com/app:1.0.0"}},
+	}
+	mgr := &apps.AppManager{
+		ClientSet: fake.NewSimpleClientset(deployment),
+		Namespace: "default",
+		Apps:      make(map[string]*apps.App),
+	}
+	if err := mgr.Scan(); err != nil {
+		T.Fatal(err)
+	}
+	if changesets := mgr.Apps["web"].ChangeSets; len(changesets) != 1 {
+		T.Errorf("Init containers should not be deployed: %v", changesets)
+	}
+	if len(mgr.Init) != 1 || len(mgr.References()) != 2 {
+		T.Errorf("Init containers should be referenced: %v", mgr.References())
+	}
+}
@@ -0,0 +1,35 @@
+package resources
+
+import (
+	"errors"
+
+	"github.com/isotoma/k8ecr/pkg/apps"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReplicaSets are the rollout history of deployments, so they are only
+// scanned for the images they might be rolled back to
+var replicasetResource = &apps.ResourceManager{
+	Kind:    "ReplicaSet",
+	History: true,
+	Resources: func(mgr *apps.AppManager) ([]interface{}, error) {
+		client := mgr.ClientSet.AppsV1().ReplicaSets(mgr.Namespace)
+		response, err := client.List(metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		empty := make([]interface{}, len(response.Items))
+		for i, item := range response.Items {
+			empty[i] = item
+		}
+		return empty, nil
+	},
+	Generator: func(item interface{}) []apps.Container {
+		r := item.(appsv1.ReplicaSet)
+		return resources(r.Name, r.ObjectMeta, r.Spec.Template.Spec)
+	},
+	Upgrade: func(mgr *apps.AppManager, image *apps.ChangeSet, resource apps.Container) error {
+		return errors.New("ReplicaSets are not upgraded directly")
+	},
+}